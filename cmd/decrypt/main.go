@@ -0,0 +1,71 @@
+// Command decrypt decrypts a Firewatch report with the recipient's private
+// key, for admins who don't have a full GPG setup.
+//
+//	decrypt -key private.asc [report.eml]
+//
+// The report is read from the named file or from stdin and may be the whole
+// email: everything outside the PGP MESSAGE block is ignored. The passphrase
+// of a protected key is read from the file given with -passphrase-file or
+// from FIREWATCH_PGP_PASSPHRASE, never from the command line.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/firewatch/internal/mailer"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "decrypt:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "path to the recipient's private key (armored or binary)")
+	passFile := fs.String("passphrase-file", "", "path to a file holding the key's passphrase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyFile == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("usage: decrypt -key private.asc [report.eml]")
+	}
+
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	passphrase := os.Getenv("FIREWATCH_PGP_PASSPHRASE")
+	if *passFile != "" {
+		b, err := os.ReadFile(*passFile)
+		if err != nil {
+			return err
+		}
+		passphrase = strings.TrimRight(string(b), "\r\n")
+	}
+
+	var msg []byte
+	if fs.NArg() == 1 {
+		msg, err = os.ReadFile(fs.Arg(0))
+	} else {
+		msg, err = io.ReadAll(stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	plain, err := mailer.DecryptBody(string(key), passphrase, string(msg))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, plain)
+	return err
+}
@@ -50,7 +50,7 @@ func New() (*App, error) {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
-	logger := newLogger(cfg)
+	logger, redactor := newLogger(cfg)
 
 	ctx := context.Background()
 	pool, err := openDB(ctx, cfg)
@@ -65,8 +65,24 @@ func New() (*App, error) {
 
 	crypter := crypto.New(cfg.SettingsEncryptionKey)
 	settingsStore := store.NewSettingsStore(pool, crypter)
+	settingsStore.Subscribe(func(s model.AppSettings) {
+		redactor.SetSecrets(s.SMTPUser, s.SMTPPass)
+	})
+
+	if cfg.SessionSecretPrevious != nil {
+		slog.Info("startup: accepting values signed with the previous session secret; unset SESSION_SECRET_PREVIOUS_FILE once sessions have rolled over")
+	}
 
 	userStore := store.NewUserStore(pool, crypter, cfg.EmailHMACKey)
+	if n, err := userStore.UpgradeEmailHMACs(ctx); err != nil {
+		slog.Error("startup: failed to upgrade email HMACs, email login may fail", "err", err)
+	} else if n > 0 {
+		slog.Info("startup: upgraded email HMACs", "users", n)
+	}
+
+	if err := auth.SetCost(cfg.BcryptCost); err != nil {
+		return nil, err
+	}
 
 	// TODO: force password reset on first login if seeded from env vars
 	auth.SeedFirstAdmin(ctx, userStore)
@@ -79,30 +95,46 @@ func New() (*App, error) {
 		slog.Warn("startup: could not load settings, starting with defaults (re-configure via Settings UI)", "err", err)
 		s = &model.AppSettings{}
 	}
-	m := mailer.New(mailer.NewConfigFromSettings(s))
-	q := mailer.NewQueue(m, time.Second, 64, 3, deliveryStore)
+	redactor.SetSecrets(s.SMTPUser, s.SMTPPass)
+	emails, err := mailer.LoadEmailTemplates(cfg.EmailTemplateDir, cfg.OrgName)
+	if err != nil {
+		return nil, err
+	}
+	mailer.UseEmailTemplates(emails)
+	mcfg := mailer.NewConfigFromSettings(s)
+	sendInterval := mailer.DefaultSendInterval
+	if mcfg.SendInterval > 0 {
+		sendInterval = mcfg.SendInterval
+	}
+	m := mailer.New(mcfg)
+	q := mailer.NewQueue(m, sendInterval, 64, 3, deliveryStore)
+	q.SetDigest(mcfg.DigestInterval, mcfg.DigestMaxBatch)
+	if okAt, failedAt, err := deliveryStore.LastEmail(ctx); err != nil {
+		slog.Warn("startup: could not read last delivery times", "err", err)
+	} else {
+		q.SetSendHealth(mailer.SendHealth{LastSuccessAt: okAt, LastErrorAt: failedAt})
+	}
+	settingsStore.Subscribe(func(s model.AppSettings) {
+		q.Reconfigure(mailer.NewConfigFromSettings(&s))
+	})
 
 	// Verify SMTP and PGP at startup so the flags reflect current reality.
-	tmp := mailer.New(mailer.NewConfigFromSettings(s))
-	if pingErr := tmp.Ping(); pingErr != nil {
-		s.SMTPVerified = false
-		s.SMTPError = pingErr.Error()
-		slog.Warn("startup: SMTP verification failed — maintenance mode forced on", "err", pingErr)
-	} else {
-		s.SMTPVerified = true
-		s.SMTPError = ""
+	if wkdErr := mailer.NewKeyFetcher().RefreshWKD(ctx, s); wkdErr != nil {
+		slog.Warn("startup: WKD lookup failed, using the configured key", "err", wkdErr)
 	}
-	if encErr := tmp.CanEncrypt(); encErr != nil {
-		s.PGPVerified = false
-		s.PGPError = encErr.Error()
-		slog.Warn("startup: PGP verification failed — maintenance mode forced on", "err", encErr)
-	} else {
-		s.PGPVerified = true
-		s.PGPError = ""
+	mailer.Verify(ctx, s)
+	if !s.SMTPVerified {
+		slog.Warn("startup: SMTP verification failed — maintenance mode forced on", "err", s.SMTPError)
+	}
+	if !s.PGPVerified {
+		slog.Warn("startup: PGP verification failed — maintenance mode forced on", "err", s.PGPError)
 	}
 	if saveErr := settingsStore.Save(ctx, s); saveErr != nil {
 		slog.Error("startup: failed to persist verification state", "err", saveErr)
 	}
+	if err := strictStartupCheck(cfg, s); err != nil {
+		return nil, err
+	}
 
 	return &App{
 		config:        cfg,
@@ -122,8 +154,12 @@ func (app App) Start(ctx context.Context) error {
 	// Create an errgroup derived from the parent context
 	g, gctx := errgroup.WithContext(ctx)
 
+	ln, err := listen(app.config)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", app.config.Port),
 		Handler:      app.routes(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  5 * time.Second,
@@ -137,10 +173,17 @@ func (app App) Start(ctx context.Context) error {
 		return nil
 	})
 
+	if app.config.VerifyInterval > 0 {
+		g.Go(func() error {
+			app.verifyLoop(gctx, app.config.VerifyInterval)
+			return nil
+		})
+	}
+
 	// Start the server in a goroutine
 	g.Go(func() error {
-		app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.Env)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Info("starting server", "addr", ln.Addr().String(), "env", app.config.Env, "onion_mode", app.config.OnionMode)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			app.logger.Error("server failed", "error", err)
 		}
 		return nil
@@ -169,6 +212,30 @@ func (app App) Start(ctx context.Context) error {
 	return nil
 }
 
+// strictStartupCheck fails when STRICT_STARTUP is set outside development
+// and either startup verification failed, so a misconfigured deploy stops
+// with an error rather than quietly serving a form in maintenance mode.
+func strictStartupCheck(cfg *config.Config, s *model.AppSettings) error {
+	if !cfg.StrictStartup {
+		return nil
+	}
+	if cfg.IsDevelopment() {
+		slog.Info("startup: STRICT_STARTUP ignored in development")
+		return nil
+	}
+	var errs []error
+	if !s.SMTPVerified {
+		errs = append(errs, fmt.Errorf("SMTP verification failed: %s", s.SMTPError))
+	}
+	if !s.PGPVerified {
+		errs = append(errs, fmt.Errorf("PGP verification failed: %s", s.PGPError))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("strict startup: %w", err)
+	}
+	return nil
+}
+
 func openDB(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", cfg.DatabaseURL)
 	if err != nil {
@@ -211,17 +278,21 @@ func runMigrations(db *sql.DB) error {
 	return m.Up()
 }
 
-func newLogger(cfg *config.Config) *slog.Logger {
+// newLogger builds the application logger and makes it the slog default, so
+// package-level slog calls are redacted too. The returned handler takes the
+// secrets to scrub as settings change.
+func newLogger(cfg *config.Config) (*slog.Logger, *redactHandler) {
 	logLevel := slog.LevelInfo
 
 	if cfg.IsDevelopment() {
 		logLevel = slog.LevelDebug
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	redactor := newRedactHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
 	}))
+	logger := slog.New(requestIDHandler{redactor})
 
 	slog.SetDefault(logger)
-	return logger
+	return logger, redactor
 }
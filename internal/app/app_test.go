@@ -0,0 +1,33 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/firewatch/internal/config"
+	"github.com/firewatch/internal/model"
+)
+
+func TestStrictStartupCheck(t *testing.T) {
+	healthy := &model.AppSettings{SMTPVerified: true, PGPVerified: true}
+	broken := &model.AppSettings{SMTPVerified: true, PGPError: "pgp: no public key configured"}
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		s       *model.AppSettings
+		wantErr bool
+	}{
+		{"lenient by default", config.Config{Env: "production"}, broken, false},
+		{"strict and healthy", config.Config{Env: "production", StrictStartup: true}, healthy, false},
+		{"strict and broken", config.Config{Env: "production", StrictStartup: true}, broken, true},
+		{"ignored in development", config.Config{Env: "development", StrictStartup: true}, broken, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := strictStartupCheck(&tt.cfg, tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("strictStartupCheck = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/firewatch/internal/config"
+)
+
+// socketMode lets the owner and group connect, so a reverse proxy running in
+// the app's group can reach the socket and nobody else can.
+const socketMode os.FileMode = 0o660
+
+// listen opens the server's listener: a TCP port by default, or a Unix domain
+// socket when PORT is unix:/path. A stale socket left by an unclean exit is
+// removed first; any other file at the path is an error rather than being
+// clobbered. The socket is created with socketMode already applied, so it is
+// never briefly open to other users, and is unlinked again when the listener
+// is closed.
+func listen(cfg *config.Config) (net.Listener, error) {
+	path := cfg.UnixSocket()
+	if path == "" {
+		return net.Listen("tcp", ":"+cfg.Port)
+	}
+
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen: %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("listen: remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := listenUnix(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listen: chmod socket: %w", err)
+	}
+	return ln, nil
+}
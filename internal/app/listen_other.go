@@ -0,0 +1,11 @@
+//go:build !unix
+
+package app
+
+import "net"
+
+// listenUnix creates the socket. There is no umask to tighten here, so
+// listen's Chmod alone sets its mode.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
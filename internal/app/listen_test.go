@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/firewatch/internal/config"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fw.sock")
+
+	// A socket left behind by a crashed process must not block startup.
+	stale, err := listen(&config.Config{Port: "unix:" + path})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen(&config.Config{Port: "unix:" + path})
+	if err != nil {
+		t.Fatalf("listen over stale socket: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if got := fi.Mode().Perm(); got != socketMode {
+		t.Errorf("socket mode = %v, want %v", got, socketMode)
+	}
+
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket not removed on close: %v", err)
+	}
+}
+
+func TestListenRefusesNonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fw.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := listen(&config.Config{Port: "unix:" + path}); err == nil {
+		ln.Close()
+		t.Fatal("listen replaced a regular file")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package app
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix creates the socket under a umask that masks out everything
+// socketMode doesn't grant. net.Listen would otherwise create it with the
+// process umask, leaving it reachable by others until listen's Chmod.
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0o777 &^ int(socketMode))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
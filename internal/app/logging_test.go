@@ -0,0 +1,44 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/firewatch/internal/middleware"
+)
+
+func TestRequestIDIsLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(requestIDHandler{slog.NewTextHandler(&buf, nil)})
+
+	h := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.ErrorContext(r.Context(), "store failed")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+
+	id := rec.Header().Get("X-Request-ID")
+	if len(id) != 16 {
+		t.Fatalf("X-Request-ID = %q, want 16 hex characters", id)
+	}
+	if !strings.Contains(buf.String(), "request_id="+id) {
+		t.Errorf("log line not tagged with %s: %s", id, buf.String())
+	}
+
+	buf.Reset()
+	logger.ErrorContext(context.Background(), "untagged")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("request_id logged outside a request: %s", buf.String())
+	}
+
+	rec2 := httptest.NewRecorder()
+	h.ServeHTTP(rec2, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	if rec2.Header().Get("X-Request-ID") == id {
+		t.Error("two requests got the same ID")
+	}
+}
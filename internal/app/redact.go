@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are attribute keys whose values never reach the log,
+// matched case-insensitively and ignoring "_" and "-".
+var sensitiveLogKeys = map[string]bool{
+	"password":   true,
+	"passphrase": true,
+	"smtppass":   true,
+	"pgpkey":     true,
+	"privatekey": true,
+	"token":      true,
+	"email":      true,
+	"to":         true,
+	"subject":    true, // may carry report field values via the subject template
+}
+
+func isSensitiveLogKey(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveLogKeys[key]
+}
+
+// redactHandler scrubs secrets from log records before passing them on. It
+// replaces the values of sensitive keys, and removes known secret strings
+// (such as the SMTP credentials, which a relay may echo back in an error)
+// from messages, string values and errors.
+type redactHandler struct {
+	next    slog.Handler
+	secrets *logSecrets // shared by every handler derived from this one
+}
+
+type logSecrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// minSecretLen keeps very short values, which would mangle unrelated text,
+// out of the scrub list.
+const minSecretLen = 4
+
+func newRedactHandler(next slog.Handler) *redactHandler {
+	return &redactHandler{next: next, secrets: &logSecrets{}}
+}
+
+// SetSecrets replaces the strings scrubbed from log output.
+func (h *redactHandler) SetSecrets(values ...string) {
+	var keep []string
+	for _, v := range values {
+		if len(v) >= minSecretLen {
+			keep = append(keep, v)
+		}
+	}
+	h.secrets.mu.Lock()
+	h.secrets.values = keep
+	h.secrets.mu.Unlock()
+}
+
+func (h *redactHandler) scrub(s string) string {
+	h.secrets.mu.RLock()
+	defer h.secrets.mu.RUnlock()
+	for _, v := range h.secrets.values {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	return s
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if isSensitiveLogKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		out := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			out[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindString:
+		return slog.String(a.Key, h.scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, h.scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = h.redact(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(out), secrets: h.secrets}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), secrets: h.secrets}
+}
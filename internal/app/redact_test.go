@@ -0,0 +1,37 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := newRedactHandler(slog.NewTextHandler(&buf, nil))
+	h.SetSecrets("relay-user", "hunter2-pass", "ab")
+	logger := slog.New(h)
+
+	logger.Info("invite sent", "email", "alice@example.org", "smtp_pass", "x", "pgpKey", "-----BEGIN")
+	logger.With("token", "abc123").Warn("ping failed",
+		"err", errors.New("535 auth failed for relay-user"),
+		"detail", "password hunter2-pass rejected",
+		slog.Group("msg", "to", "bob@example.org", "size", 12),
+	)
+	logger.Info("about")
+
+	out := buf.String()
+	for _, leak := range []string{"alice@example.org", "bob@example.org", "relay-user", "hunter2-pass", "abc123", "BEGIN"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("log output leaks %q:\n%s", leak, out)
+		}
+	}
+	// Short secrets are ignored, and non-sensitive attributes survive.
+	for _, keep := range []string{"msg.size=12", "535 auth failed for [REDACTED]", "invite sent", "msg=about"} {
+		if !strings.Contains(out, keep) {
+			t.Errorf("log output missing %q:\n%s", keep, out)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/firewatch/internal/config"
 	"github.com/firewatch/internal/handler"
 	"github.com/firewatch/internal/middleware"
 	"github.com/firewatch/internal/web"
@@ -13,78 +14,141 @@ import (
 )
 
 func (app App) routes() http.Handler {
+	web.SetBasePath(app.config.BasePath)
+
 	r := chi.NewRouter()
 	r.Use(chimw.Recoverer)
-	r.Use(middleware.SecurityHeaders)
+	r.Use(middleware.SecurityHeaders(app.config.OnionMode))
 	r.Use(middleware.CSP)
 
 	// Static files
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServerFS(web.StaticFS)))
 
 	// Health check
-	r.Get("/api/health", handler.Health(app.db))
+	r.Get("/api/health", handler.Health(app.db, app.mailerQueue))
+
+	// Submission counts shared by the report form and the stats dashboard.
+	activity := handler.NewActivityCounter()
+
+	// Compression is opt-in per route, for the large schema and page
+	// responses. Routes whose responses carry secrets (settings, users, auth)
+	// or report content (preview, decrypt test, submit) are left uncompressed
+	// so response sizes can't leak them, BREACH-style.
+	compressMW := chimw.Compress(5, "text/html", "application/json")
 
 	// Public report form
-	reportHandler := handler.NewReportHandler(app.logger, app.schemaStore, app.sessionStore, app.mailerQueue, app.reportStore, app.deliveryStore, web.Templates)
+	reportHandler := handler.NewReportHandler(app.logger, app.schemaStore, app.settingsStore, app.sessionStore, app.mailerQueue, app.reportStore, app.deliveryStore, web.Templates, app.config.MaxReportBodyBytes, app.config.SessionKeys(), app.config.SubmissionLogLevel, activity)
 	r.Get("/admin", reportHandler.RedirectToLogin)
 	r.Get("/login", reportHandler.RedirectToLogin)
 
 	// Maintenance-guarded public routes
 	maintenanceMW := middleware.MaintenanceMode(app.settingsStore, web.Templates)
-	ratelimitMW := middleware.RateLimit(rate.Every(time.Minute/10), 5, app.config.TrustedProxy) // 10 requests per minute with burst of 5
+	// Behind a Unix socket the peer is always the local proxy, so the per-IP
+	// limiters read the client address from its forwarded headers.
+	behindSocket := app.config.UnixSocket() != ""
+	ratelimitMW := middleware.GlobalRateLimit(rate.Every(time.Minute/60), 20) // 60 submissions per minute across all clients, burst of 20
+	if app.config.PublicRateLimitMode == config.RateLimitPerIP {
+		ratelimitMW = middleware.RateLimit(rate.Every(time.Minute/10), 5, app.config.TrustedProxies, behindSocket) // 10 requests per minute with burst of 5
+	}
+	r.With(maintenanceMW, compressMW).Get("/", reportHandler.Form)
+
+	// The public API may also be called from mirror front-ends on the
+	// CORS_ALLOWED_ORIGINS. CORS runs before the maintenance check so a 503
+	// can still be read cross-origin.
 	r.Group(func(r chi.Router) {
+		r.Use(middleware.CORS(app.config.CORSOrigins))
 		r.Use(maintenanceMW)
-		r.Get("/", reportHandler.Form)
-		r.Get("/api/report", reportHandler.Get)
+		r.With(compressMW).Get("/api/report", reportHandler.Get)
+		r.With(compressMW).Get("/api/report/definition", reportHandler.Definition)
+		r.Get("/api/report/schema-version", reportHandler.SchemaVersion)
+		r.Get("/api/report/form-token", reportHandler.FormToken)
 		r.With(ratelimitMW).Post("/api/report", reportHandler.Submit)
+		if len(app.config.CORSOrigins) > 0 {
+			// Preflights are answered by the CORS middleware; these routes
+			// only make sure OPTIONS requests reach it.
+			r.Options("/api/report", preflightOnly)
+			r.Options("/api/report/definition", preflightOnly)
+			r.Options("/api/report/schema-version", preflightOnly)
+			r.Options("/api/report/form-token", preflightOnly)
+		}
 	})
 
 	// Admin auth (public endpoints)
-	loginRatelimitMW := middleware.RateLimit(rate.Every(10*time.Minute/5), 5, app.config.TrustedProxy) // 5 login attempts per 10 minutes with burst of 5
-	authHandler := handler.NewAuthHandler(app.userStore, app.sessionStore, app.userStore, web.Templates, app.config.SecureCookies, app.config.SessionSecret)
-	r.Get("/admin/login", authHandler.LoginPage)
-	r.With(loginRatelimitMW).Post("/api/admin/login", authHandler.Login)
-	r.Get("/accept-invite", authHandler.AcceptInvitePage)
-	r.Post("/api/accept-invite", authHandler.AcceptInvite)
-
-	// Protected admin routes
-	sessionMW := middleware.Session(app.config.SessionSecret, app.sessionStore, app.userStore)
+	loginRatelimitMW := middleware.RateLimit(rate.Every(10*time.Minute/5), 5, app.config.TrustedProxies, behindSocket) // 5 login attempts per 10 minutes with burst of 5
+	authHandler := handler.NewAuthHandler(app.userStore, app.sessionStore, app.userStore, web.Templates, app.config.SecureCookies, app.config.SessionSameSite, app.config.SessionKeys())
+	r.With(middleware.RequestID).Get("/admin/login", authHandler.LoginPage)
+	r.With(middleware.RequestID, loginRatelimitMW).Post("/api/admin/login", authHandler.Login)
+	// The page and the form share one limiter, since both look up the token.
+	// A throttled request gets a bare 429 and no hint about the token.
+	inviteRatelimitMW := middleware.RateLimit(rate.Every(10*time.Minute/10), 10, app.config.TrustedProxies, behindSocket) // 10 invite lookups per 10 minutes with burst of 10
+	r.With(middleware.RequestID, inviteRatelimitMW).Get("/accept-invite", authHandler.AcceptInvitePage)
+	r.With(middleware.RequestID, inviteRatelimitMW).Post("/api/accept-invite", authHandler.AcceptInvite)
+
+	// Protected admin routes. Request IDs are assigned before the session
+	// check so its log lines are tagged too.
+	sessionMW := middleware.Session(app.config.SessionKeys(), app.sessionStore, app.userStore)
 	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequestID)
+		if app.config.SessionSameSite == http.SameSiteLaxMode {
+			r.Use(middleware.RejectCrossOrigin)
+		}
 		r.Use(sessionMW)
 		r.Use(middleware.ForcePasswordChange)
 
 		r.Post("/api/admin/logout", authHandler.Logout)
 		r.Get("/admin/change-password", authHandler.ChangePasswordPage)
 		r.Post("/api/admin/change-password", authHandler.ChangePassword)
+		r.Get("/admin/change-email", authHandler.ChangeEmailPage)
+		r.Post("/api/admin/change-email", authHandler.ChangeEmail)
 
-		statsHandler := handler.NewStatsHandler(app.logger, app.reportStore, app.schemaStore, app.deliveryStore, web.Templates)
-		r.Get("/admin/stats", statsHandler.Page)
+		sessionHandler := handler.NewSessionHandler(app.logger, app.sessionStore, app.config.SessionWarnBefore, app.config.SecureCookies, app.config.SessionSameSite)
+		r.Get("/api/admin/session", sessionHandler.Status)
+		r.Post("/api/admin/session/refresh", sessionHandler.Refresh)
+
+		statsHandler := handler.NewStatsHandler(app.logger, app.reportStore, app.schemaStore, app.deliveryStore, activity, web.Templates)
+		r.With(compressMW).Get("/admin/stats", statsHandler.Page)
+		r.With(compressMW).Get("/api/admin/stats/activity", statsHandler.Activity)
 
 		adminReportHandler := handler.NewAdminReportHandler(app.logger, app.schemaStore, web.Templates)
-		r.Get("/admin/report", adminReportHandler.Page)
-		r.Get("/api/admin/report", adminReportHandler.Get)
+		r.With(compressMW).Get("/admin/report", adminReportHandler.Page)
+		r.With(compressMW).Get("/api/admin/report", adminReportHandler.Get)
 		r.Put("/api/admin/report", adminReportHandler.Update)
 		r.Post("/api/admin/report/apply", adminReportHandler.Apply)
 		r.Post("/api/admin/report/revert", adminReportHandler.Revert)
+		r.With(compressMW).Get("/api/admin/report/history", adminReportHandler.History)
+		r.Post("/api/admin/report/rollback", adminReportHandler.Rollback)
 
-		settingsHandler := handler.NewSettingsHandler(app.logger, app.settingsStore, app.mailerQueue, web.Templates)
+		settingsHandler := handler.NewSettingsHandler(app.logger, app.settingsStore, app.schemaStore, app.mailerQueue, web.Templates)
 		r.Get("/admin/settings", settingsHandler.Page)
 		r.Get("/api/admin/settings", settingsHandler.Get)
 		r.Put("/api/admin/settings", settingsHandler.Update)
 		r.Post("/api/admin/settings/apply", settingsHandler.Apply)
 		r.Post("/api/admin/settings/test-email", settingsHandler.TestEmail)
+		r.Post("/api/admin/settings/preview-report", settingsHandler.PreviewReport)
+		r.Post("/api/admin/settings/sample-report", settingsHandler.SampleReport)
+		r.Post("/api/admin/settings/decrypt-test", settingsHandler.DecryptTest)
+		r.Post("/api/admin/settings/fetch-pgp-key", settingsHandler.FetchPGPKey)
 
 		// Super admin only
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.RequireSuperAdmin())
 
-			usersHandler := handler.NewUsersHandler(app.userStore, app.sessionStore, app.mailerQueue, app.config.AdminInviteBaseURL, web.Templates)
+			usersHandler := handler.NewUsersHandler(app.logger, app.userStore, app.sessionStore, app.mailerQueue, app.config.AdminInviteBaseURL, web.Templates)
 			r.Get("/admin/users", usersHandler.Page)
 			r.Get("/api/admin/users", usersHandler.List)
 			r.Post("/api/admin/users", usersHandler.Invite)
 			r.Put("/api/admin/users/{id}", usersHandler.Update)
 			r.Delete("/api/admin/users/{id}", usersHandler.Delete)
+
+			panicWipeHandler := handler.NewPanicWipeHandler(app.logger, app.settingsStore, app.userStore, app.config.SecureCookies, app.config.SessionSameSite)
+			r.Post("/api/admin/panic-wipe", panicWipeHandler.Wipe)
 		})
 	})
-	return r
+	return middleware.BasePath(app.config.BasePath)(r)
+}
+
+// preflightOnly answers a plain OPTIONS request on a CORS-enabled route.
+func preflightOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", "GET, POST, OPTIONS")
+	w.WriteHeader(http.StatusNoContent)
 }
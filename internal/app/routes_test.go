@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/firewatch/internal/config"
+	"github.com/firewatch/internal/crypto"
+	"github.com/firewatch/internal/mailer"
+	"github.com/firewatch/internal/store"
+)
+
+// newTestApp returns an App backed by a migrated SQLite database in a temp
+// directory, with no mail relay configured.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	cfg := &config.Config{
+		Env:           "development",
+		DatabaseURL:   "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=journal_mode(WAL)&_pragma=foreign_keys(on)",
+		SessionSecret: make([]byte, 32),
+	}
+	db, err := openDB(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	crypter := crypto.New(make([]byte, 32))
+	deliveryStore := store.NewDeliveryStore(db)
+	return &App{
+		config:        cfg,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		db:            db,
+		schemaStore:   store.NewSchemaStore(db),
+		userStore:     store.NewUserStore(db, crypter, make([]byte, 32)),
+		sessionStore:  store.NewSessionStore(db),
+		settingsStore: store.NewSettingsStore(db, crypter),
+		reportStore:   store.NewReportStore(db),
+		deliveryStore: deliveryStore,
+		mailerQueue:   mailer.NewQueue(mailer.New(&mailer.Config{}), mailer.DefaultSendInterval, 4, 0, deliveryStore),
+	}
+}
+
+func TestAcceptInviteRateLimit(t *testing.T) {
+	srv := httptest.NewServer(newTestApp(t).routes())
+	defer srv.Close()
+
+	const invalid = "This invitation link is invalid or has expired."
+	for i := 1; i <= 10; i++ {
+		resp, err := http.Get(srv.URL + "/accept-invite?token=bogus")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), invalid) {
+			t.Fatalf("lookup %d: got %d, want 200 with the invalid-invite message", i, resp.StatusCode)
+		}
+	}
+
+	// The form shares the page's limiter, and a throttled request learns
+	// nothing about the token.
+	resp, err := http.PostForm(srv.URL+"/api/accept-invite", map[string][]string{"token": {"bogus"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("11th lookup: got %d, want 429", resp.StatusCode)
+	}
+	if got := string(body); got != "Too Many Requests\n" {
+		t.Errorf("429 body = %q, want the bare status text", got)
+	}
+}
+
+func TestLoginRateLimitOverUnixSocket(t *testing.T) {
+	app := newTestApp(t)
+	path := filepath.Join(t.TempDir(), "fw.sock")
+	app.config.Port = "unix:" + path
+	ln, err := listen(app.config)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: app.routes()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	login := func(clientAddr string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, "http://firewatch/api/admin/login", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", clientAddr)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 1; i <= 5; i++ {
+		if code := login("192.0.2.10"); code == http.StatusTooManyRequests {
+			t.Fatalf("attempt %d throttled early", i)
+		}
+	}
+	if code := login("192.0.2.10"); code != http.StatusTooManyRequests {
+		t.Errorf("6th attempt from one client: got %d, want 429", code)
+	}
+	// Another client behind the same proxy keeps its own bucket.
+	if code := login("198.51.100.10"); code == http.StatusTooManyRequests {
+		t.Error("a second client was locked out by the first")
+	}
+}
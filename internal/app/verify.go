@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/firewatch/internal/mailer"
+)
+
+// verifyLoop re-runs SMTP and PGP verification every interval, so a relay
+// outage or key problem at save time doesn't leave the public form disabled
+// until an admin re-applies settings.
+func (app App) verifyLoop(ctx context.Context, interval time.Duration) {
+	keys := mailer.NewKeyFetcher()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			app.reverify(ctx, keys)
+		}
+	}
+}
+
+// reverify runs one verification pass and persists the result if anything
+// changed. Saving notifies the store's subscribers, so the mailer picks up a
+// refreshed WKD key too.
+func (app App) reverify(ctx context.Context, keys *mailer.KeyFetcher) {
+	before, err := app.settingsStore.Load(ctx)
+	if err != nil {
+		app.logger.Warn("verify: could not load settings", "err", err)
+		return
+	}
+	s := *before
+	if err := keys.RefreshWKD(ctx, &s); err != nil && before.PGPWKDKey != "" {
+		app.logger.Warn("verify: WKD lookup failed, falling back to the configured key", "err", err)
+	}
+	mailer.Verify(ctx, &s)
+	if s == *before {
+		return
+	}
+
+	// An admin save while we were checking ran its own verification against
+	// newer settings; don't overwrite it with ours.
+	current, err := app.settingsStore.Load(ctx)
+	if err != nil || *current != *before {
+		return
+	}
+	if err := app.settingsStore.Save(ctx, &s); err != nil {
+		app.logger.Error("verify: failed to persist verification state", "err", err)
+		return
+	}
+
+	app.logTransition("SMTP", before.SMTPVerified, s.SMTPVerified, s.SMTPError)
+	app.logTransition("PGP", before.PGPVerified, s.PGPVerified, s.PGPError)
+	switch wasDown, isDown := before.UnavailableReason() != "", s.UnavailableReason() != ""; {
+	case wasDown && !isDown:
+		app.logger.Info("verify: public form available again")
+	case !wasDown && isDown:
+		app.logger.Warn("verify: public form disabled", "reason", s.UnavailableReason())
+	}
+}
+
+func (app App) logTransition(check string, was, is bool, errMsg string) {
+	switch {
+	case !was && is:
+		app.logger.Info("verify: "+check+" verification recovered", "check", check)
+	case was && !is:
+		app.logger.Warn("verify: "+check+" verification failed", "check", check, "err", errMsg)
+	}
+}
@@ -4,13 +4,27 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-const bcryptCost = 12
+// DefaultBcryptCost is the bcrypt work factor used unless SetCost is called.
+const DefaultBcryptCost = 12
+
+var bcryptCost = DefaultBcryptCost
+
+// SetCost sets the bcrypt work factor used by Hash and NeedsRehash.
+func SetCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost %d out of range [%d, %d]", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	bcryptCost = cost
+	return nil
+}
 
 // Hash returns a bcrypt hash of the password.
 func Hash(password string) (string, error) {
@@ -23,9 +37,16 @@ func Verify(hash, password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
-// NewID generates a random hex ID.
+// NeedsRehash reports whether hash was made with a lower cost than the one
+// currently configured, so it should be replaced after a successful Verify.
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < bcryptCost
+}
+
+// NewID generates a random 128-bit hex ID.
 func NewID() string {
-	b := make([]byte, 8)
+	b := make([]byte, 16)
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
 }
@@ -44,14 +65,33 @@ type UserCreator interface {
 	SetMustChangePassword(ctx context.Context, id string, v bool) error
 }
 
+// UsernameFromEmail derives a username from the local part of an email
+// address, dropping whitespace and capping it at 64 characters.
+func UsernameFromEmail(email string) string {
+	local, _, _ := strings.Cut(strings.TrimSpace(email), "@")
+	local = strings.Join(strings.Fields(local), "")
+	if len(local) > 64 {
+		local = local[:64]
+	}
+	return local
+}
+
 // SeedFirstAdmin creates the initial super_admin account from env vars if the
-// admin_users table is empty. The seeded account is flagged to require a
-// password change on first login.
+// admin_users table is empty. SEED_ADMIN_USERNAME is optional and defaults to
+// the local part of SEED_ADMIN_EMAIL. The seeded account is flagged to require
+// a password change on first login.
 func SeedFirstAdmin(ctx context.Context, users UserCreator) {
-	username := os.Getenv("SEED_ADMIN_USERNAME")
+	username := strings.TrimSpace(os.Getenv("SEED_ADMIN_USERNAME"))
 	email := os.Getenv("SEED_ADMIN_EMAIL")
 	password := os.Getenv("SEED_ADMIN_PASSWORD")
-	if username == "" || email == "" || password == "" {
+	if email == "" || password == "" {
+		return
+	}
+	if username == "" {
+		username = UsernameFromEmail(email)
+	}
+	if username == "" {
+		slog.Error("seed: could not derive a username from SEED_ADMIN_EMAIL; set SEED_ADMIN_USERNAME")
 		return
 	}
 
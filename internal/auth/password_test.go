@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeUserCreator struct {
+	count    int
+	username string
+	email    string
+	role     string
+	mustCPW  bool
+}
+
+func (f *fakeUserCreator) CountAll(ctx context.Context) (int, error) { return f.count, nil }
+
+func (f *fakeUserCreator) Create(ctx context.Context, id, username, email, passwordHash, role string) error {
+	f.count++
+	f.username, f.email, f.role = username, email, role
+	return nil
+}
+
+func (f *fakeUserCreator) SetMustChangePassword(ctx context.Context, id string, v bool) error {
+	f.mustCPW = v
+	return nil
+}
+
+func TestSeedFirstAdminUsername(t *testing.T) {
+	cases := []struct {
+		name     string
+		username string
+		email    string
+		want     string
+	}{
+		{"explicit username", "root", "admin@example.org", "root"},
+		{"derived from email", "", "ops.team@example.org", "ops.team"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("SEED_ADMIN_USERNAME", tc.username)
+			t.Setenv("SEED_ADMIN_EMAIL", tc.email)
+			t.Setenv("SEED_ADMIN_PASSWORD", "correct-horse-battery")
+
+			users := &fakeUserCreator{}
+			SeedFirstAdmin(context.Background(), users)
+
+			if users.username == "" {
+				t.Fatal("seeded user has an empty username")
+			}
+			if users.username != tc.want {
+				t.Errorf("expected username %q, got %q", tc.want, users.username)
+			}
+			if users.email != tc.email || users.role != "super_admin" || !users.mustCPW {
+				t.Errorf("unexpected seed: email=%q role=%q mustChangePassword=%v", users.email, users.role, users.mustCPW)
+			}
+		})
+	}
+}
+
+func TestSeedFirstAdminSkipsWhenUsersExist(t *testing.T) {
+	t.Setenv("SEED_ADMIN_EMAIL", "admin@example.org")
+	t.Setenv("SEED_ADMIN_PASSWORD", "correct-horse-battery")
+
+	users := &fakeUserCreator{count: 1}
+	SeedFirstAdmin(context.Background(), users)
+
+	if users.username != "" {
+		t.Errorf("expected no user to be created, got %q", users.username)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if len(a) != 32 {
+		t.Errorf("len(NewID()) = %d, want 32 hex chars", len(a))
+	}
+	if a == b {
+		t.Error("NewID returned the same value twice")
+	}
+}
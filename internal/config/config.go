@@ -5,14 +5,31 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Public form rate limiting modes.
+const (
+	RateLimitGlobal = "global"
+	RateLimitPerIP  = "per-ip"
+)
+
+// LogLevelOff is above every level slog emits, so a logger check against it
+// always fails. SUBMISSION_LOG_LEVEL=off maps to it.
+const LogLevelOff = slog.LevelError + 4
+
 type Config struct {
 	// Server
-	Port string
+	Port string // TCP port, or unix:/path/to/sock for a Unix domain socket
 	Env  string // development, production
 
 	// Database
@@ -20,11 +37,13 @@ type Config struct {
 
 	// File paths to 32-byte binary key files.
 	SessionSecretFile         string
+	SessionSecretPreviousFile string // optional; set while rotating SESSION_SECRET_FILE
 	SettingsEncryptionKeyFile string
 	EmailHMACKeyFile          string
 
 	// Decoded key bytes — populated during Validate(), never set from env directly.
 	SessionSecret         []byte
+	SessionSecretPrevious []byte // nil unless SESSION_SECRET_PREVIOUS_FILE is set
 	SettingsEncryptionKey []byte
 	EmailHMACKey          []byte
 
@@ -40,12 +59,70 @@ type Config struct {
 
 	AdminInviteBaseURL string
 
+	// BasePath is the URL prefix the app is served under behind a reverse
+	// proxy, e.g. "/firewatch". Empty when served at the root.
+	BasePath string
+
+	// OrgName is the organisation name used in invite and report emails.
+	OrgName string
+
+	// EmailTemplateDir, when set, holds invite.txt and/or report.txt that
+	// replace the built-in email templates.
+	EmailTemplateDir string
+
 	SecureCookies bool
 
-	// TrustedProxy is the CIDR of a trusted reverse proxy (e.g. 127.0.0.1/32).
-	// When set, X-Real-IP / X-Forwarded-For are trusted only from that range.
-	// Nil means no proxy is trusted and the raw TCP connection IP is always used.
-	TrustedProxy *net.IPNet
+	// SessionSameSite is the SameSite mode of the admin session cookie.
+	// Strict by default; Lax lets an admin arriving from a link on another
+	// site, such as an invite opened in webmail, stay signed in, and turns on
+	// middleware.RejectCrossOrigin for the admin routes to make up for it.
+	SessionSameSite http.SameSite
+
+	// SessionWarnBefore is how long before the session expires the admin UI
+	// warns and offers to extend it.
+	SessionWarnBefore time.Duration
+
+	// OnionMode is for serving as a Tor onion service, where the app is
+	// reached over plain HTTP but Tor encrypts and authenticates the
+	// connection. It turns off HSTS and the Secure cookie flag, which would
+	// otherwise break the site or its cookies over http://*.onion.
+	OnionMode bool
+
+	// MaxReportBodyBytes caps the size of a public report submission body.
+	MaxReportBodyBytes int64
+
+	// PublicRateLimitMode selects how the public report form is rate limited:
+	// "global" (one shared limiter, the default) or "per-ip".
+	PublicRateLimitMode string
+
+	// BcryptCost is the work factor for new password hashes. Existing hashes
+	// with a lower cost are upgraded on the user's next login.
+	BcryptCost int
+
+	// SubmissionLogLevel is the level of the one-line summary logged for each
+	// report submission. LogLevelOff disables it.
+	SubmissionLogLevel slog.Level
+
+	// VerifyInterval is how often SMTP and PGP verification is re-run in the
+	// background, so the public form recovers on its own after an outage.
+	// Zero disables the check.
+	VerifyInterval time.Duration
+
+	// StrictStartup makes the server refuse to start when the startup SMTP
+	// or PGP check fails, instead of coming up in maintenance mode. It has
+	// no effect in development.
+	StrictStartup bool
+
+	// TrustedProxies are the CIDRs of trusted reverse proxies (e.g.
+	// 127.0.0.1/32). X-Forwarded-For / X-Real-IP are honored only on
+	// connections from these ranges. Empty means no proxy is trusted and the
+	// raw TCP connection IP is always used.
+	TrustedProxies []*net.IPNet
+
+	// CORSOrigins are the origins, such as "https://mirror.example.org", whose
+	// pages may call the public report API cross-origin; "*" allows any.
+	// Empty means same-origin only.
+	CORSOrigins []string
 }
 
 func Load() (*Config, error) {
@@ -55,11 +132,13 @@ func Load() (*Config, error) {
 	cfg := &Config{}
 
 	// Define flags with env var fallbacks
-	flag.StringVar(&cfg.Port, "port", getEnv("PORT", "8080"), "Server port")
+	flag.StringVar(&cfg.Port, "port", getEnv("PORT", "8080"), "Server port, or unix:/path/to/sock")
 	flag.StringVar(&cfg.Env, "env", getEnv("ENV", "development"), "Environment (development, production)")
+	flag.BoolVar(&cfg.StrictStartup, "strict", getEnv("STRICT_STARTUP", "false") == "true", "Exit at startup if SMTP or PGP verification fails")
 	flag.StringVar(&cfg.DatabaseURL, "database-url", getEnv("DATABASE_URL", ""), "PostgreSQL connection string")
 
 	cfg.SessionSecretFile = mustEnv("SESSION_SECRET_FILE")
+	cfg.SessionSecretPreviousFile = getEnv("SESSION_SECRET_PREVIOUS_FILE", "")
 	cfg.SettingsEncryptionKeyFile = mustEnv("SETTINGS_ENCRYPTION_KEY_FILE")
 	cfg.EmailHMACKeyFile = mustEnv("EMAIL_HMAC_KEY_FILE")
 	cfg.SMTPHost = getEnv("SMTP_HOST", "")
@@ -71,15 +150,75 @@ func Load() (*Config, error) {
 	cfg.DestinationEmail = getEnv("DESTINATION_EMAIL", "")
 	cfg.ReportRetentionPolicy = getEnv("REPORT_RETENTION_POLICY", "30d")
 	cfg.AdminInviteBaseURL = getEnv("ADMIN_INVITE_BASE_URL", "")
+	basePath, err := normalizeBasePath(getEnv("BASE_PATH", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.BasePath = basePath
+	cfg.OrgName = getEnv("ORG_NAME", "Firewatch")
+	cfg.EmailTemplateDir = getEnv("EMAIL_TEMPLATE_DIR", "")
 	cfg.SecureCookies = getEnv("SECURE_COOKIES", "false") == "true"
+	cfg.OnionMode = getEnv("ONION_MODE", "false") == "true"
+	if cfg.OnionMode {
+		cfg.SecureCookies = false
+	}
+	sameSite, err := parseSameSite(getEnv("SESSION_SAMESITE", "strict"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.SessionSameSite = sameSite
 
-	if cidr := getEnv("TRUSTED_PROXY", ""); cidr != "" {
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid TRUSTED_PROXY CIDR %q: %w", cidr, err)
-		}
-		cfg.TrustedProxy = network
+	warn := getEnv("SESSION_WARN_BEFORE", "5m")
+	warnBefore, err := time.ParseDuration(warn)
+	if err != nil || warnBefore <= 0 {
+		return nil, fmt.Errorf("invalid SESSION_WARN_BEFORE %q: must be a positive duration such as 5m", warn)
+	}
+	cfg.SessionWarnBefore = warnBefore
+
+	proxies, err := parseTrustedProxies(getEnv("TRUSTED_PROXY", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies = proxies
+
+	origins, err := parseCORSOrigins(getEnv("CORS_ALLOWED_ORIGINS", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.CORSOrigins = origins
+
+	maxBody := getEnv("MAX_REPORT_BODY_BYTES", "1048576")
+	n, err := strconv.ParseInt(maxBody, 10, 64)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid MAX_REPORT_BODY_BYTES %q: must be a positive integer", maxBody)
+	}
+	cfg.MaxReportBodyBytes = n
+
+	cfg.PublicRateLimitMode = getEnv("PUBLIC_RATE_LIMIT_MODE", RateLimitGlobal)
+	if cfg.PublicRateLimitMode != RateLimitGlobal && cfg.PublicRateLimitMode != RateLimitPerIP {
+		return nil, fmt.Errorf("invalid PUBLIC_RATE_LIMIT_MODE %q: must be %q or %q", cfg.PublicRateLimitMode, RateLimitGlobal, RateLimitPerIP)
+	}
+
+	cost := getEnv("BCRYPT_COST", "12")
+	c, err := strconv.Atoi(cost)
+	if err != nil || c < bcrypt.MinCost || c > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid BCRYPT_COST %q: must be an integer between %d and %d", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	cfg.BcryptCost = c
+
+	level := getEnv("SUBMISSION_LOG_LEVEL", "info")
+	if level == "off" {
+		cfg.SubmissionLogLevel = LogLevelOff
+	} else if err := cfg.SubmissionLogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid SUBMISSION_LOG_LEVEL %q: must be debug, info, warn, error or off", level)
+	}
+
+	interval := getEnv("VERIFY_INTERVAL", "5m")
+	d, err := time.ParseDuration(interval)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("invalid VERIFY_INTERVAL %q: must be a duration such as 5m, or 0 to disable", interval)
 	}
+	cfg.VerifyInterval = d
 
 	flag.Parse()
 
@@ -95,12 +234,24 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("DATABASE_URL is required")
 	}
 
+	if strings.HasPrefix(c.Port, unixSocketPrefix) && !filepath.IsAbs(c.UnixSocket()) {
+		return fmt.Errorf("invalid PORT %q: socket path must be absolute, e.g. unix:/run/firewatch/firewatch.sock", c.Port)
+	}
+
 	sessionKey, err := loadKeyFile(c.SessionSecretFile, "SESSION_SECRET_FILE")
 	if err != nil {
 		return err
 	}
 	c.SessionSecret = sessionKey
 
+	if c.SessionSecretPreviousFile != "" {
+		previous, err := loadKeyFile(c.SessionSecretPreviousFile, "SESSION_SECRET_PREVIOUS_FILE")
+		if err != nil {
+			return err
+		}
+		c.SessionSecretPrevious = previous
+	}
+
 	key, err := loadKeyFile(c.SettingsEncryptionKeyFile, "SETTINGS_ENCRYPTION_KEY_FILE")
 	if err != nil {
 		return err
@@ -132,6 +283,29 @@ func loadKeyFile(path, envVar string) ([]byte, error) {
 	return key, nil
 }
 
+// SessionKeys returns the keys session cookies and form tokens are verified
+// against, current first. New values are always signed with the first; the
+// previous secret, when set, only keeps values issued before a rotation valid.
+func (c *Config) SessionKeys() [][]byte {
+	if c.SessionSecretPrevious == nil {
+		return [][]byte{c.SessionSecret}
+	}
+	return [][]byte{c.SessionSecret, c.SessionSecretPrevious}
+}
+
+// unixSocketPrefix marks a PORT value as a Unix domain socket path.
+const unixSocketPrefix = "unix:"
+
+// UnixSocket returns the socket path when PORT has the form unix:/path, or ""
+// when the server listens on TCP.
+func (c *Config) UnixSocket() string {
+	path, ok := strings.CutPrefix(c.Port, unixSocketPrefix)
+	if !ok {
+		return ""
+	}
+	return path
+}
+
 func (c *Config) IsDevelopment() bool {
 	return c.Env == "development"
 }
@@ -155,3 +329,78 @@ func mustEnv(key string) string {
 	os.Exit(1)
 	return ""
 }
+
+// parseTrustedProxies parses a comma-separated list of CIDRs. A bare address
+// is taken as a single host.
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXY entry %q: must be a CIDR or IP address", entry)
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+// parseSameSite parses SESSION_SAMESITE. None is not offered: the session
+// cookie never needs to travel on cross-site subrequests.
+func parseSameSite(v string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	}
+	return 0, fmt.Errorf("invalid SESSION_SAMESITE %q: must be %q or %q", v, "strict", "lax")
+}
+
+// parseCORSOrigins parses a comma-separated list of origins, each a scheme
+// and host with an optional port and nothing else, or "*". Origins are
+// lower-cased to match what browsers send.
+func parseCORSOrigins(list string) ([]string, error) {
+	var origins []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			origins = append(origins, entry)
+			continue
+		}
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS entry %q: must be an origin such as https://example.org", entry)
+		}
+		origins = append(origins, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return origins, nil
+}
+
+// normalizeBasePath turns "firewatch", "/firewatch/" and so on into
+// "/firewatch", and "/" into "".
+func normalizeBasePath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(p, "?#%\\ ") || strings.Contains(p, "//") {
+		return "", fmt.Errorf("invalid BASE_PATH %q: must be a plain path such as /firewatch", p)
+	}
+	return "/" + p, nil
+}
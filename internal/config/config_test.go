@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig returns a Config that passes Validate, with its key files in a
+// temp directory.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	keyFile := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 32), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	return &Config{
+		Port:                      "8080",
+		DatabaseURL:               "file:" + filepath.Join(dir, "test.db"),
+		SessionSecretFile:         keyFile("session.key"),
+		SettingsEncryptionKeyFile: keyFile("settings.key"),
+		EmailHMACKeyFile:          keyFile("hmac.key"),
+	}
+}
+
+func TestValidateUnixSocketPath(t *testing.T) {
+	cases := []struct {
+		port    string
+		wantErr bool
+	}{
+		{"8080", false},
+		{"unix:/run/firewatch/firewatch.sock", false},
+		{"unix:firewatch.sock", true},
+		{"unix:./run/firewatch.sock", true},
+		{"unix:", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.port, func(t *testing.T) {
+			cfg := validConfig(t)
+			cfg.Port = tc.port
+			err := cfg.Validate()
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "must be absolute") {
+					t.Errorf("Validate() = %v, want a relative socket path error", err)
+				}
+			} else if err != nil {
+				t.Errorf("Validate() = %v", err)
+			}
+		})
+	}
+}
@@ -29,6 +29,13 @@ func New(key []byte) *Crypter {
 // Encrypt encrypts plaintext using AES-256-GCM and returns ciphertext with
 // the nonce prepended.
 func (c *Crypter) Encrypt(plaintext []byte) ([]byte, error) {
+	return c.EncryptWithContext(plaintext, nil)
+}
+
+// EncryptWithContext is Encrypt with additional authenticated data. aad is not
+// stored in the ciphertext; it binds the ciphertext to its purpose (for example
+// "settings" or "email:<userID>") and must be passed again to DecryptWithContext.
+func (c *Crypter) EncryptWithContext(plaintext, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(c.key)
 	if err != nil {
 		return nil, err
@@ -41,21 +48,32 @@ func (c *Crypter) Encrypt(plaintext []byte) ([]byte, error) {
 	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, aad)
 	return ciphertext, nil
 }
 
+// emailHMACDomain prefixes every email HMAC input so the digest can't be
+// mistaken for an HMAC computed for some other purpose with a related key.
+const emailHMACDomain = "email:"
+
 // EmailHMAC normalises the email address (lowercase, trimmed) and returns its
 // HMAC-SHA256 hex digest using the provided key.
 func EmailHMAC(key []byte, email string) string {
 	normalised := strings.ToLower(strings.TrimSpace(email))
 	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(emailHMACDomain))
 	mac.Write([]byte(normalised))
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // Decrypt decrypts ciphertext produced by Encrypt.
 func (c *Crypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.DecryptWithContext(ciphertext, nil)
+}
+
+// DecryptWithContext decrypts ciphertext produced by EncryptWithContext. It
+// fails if aad differs from the value used at encryption time.
+func (c *Crypter) DecryptWithContext(ciphertext, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(c.key)
 	if err != nil {
 		return nil, err
@@ -69,5 +87,12 @@ func (c *Crypter) Decrypt(ciphertext []byte) ([]byte, error) {
 		return nil, errors.New("crypto: ciphertext too short")
 	}
 	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// Zero overwrites b with zeros. Call it on decrypted buffers once the needed
+// value has been copied out. This is best effort: the GC may already have
+// copied the data, and strings made from b are not affected.
+func Zero(b []byte) {
+	clear(b)
 }
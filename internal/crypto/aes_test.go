@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptWithContext(t *testing.T) {
+	c := New(bytes.Repeat([]byte{7}, 32))
+
+	ct, err := c.EncryptWithContext([]byte("secret"), []byte("email:u1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, err := c.DecryptWithContext(ct, []byte("email:u1"))
+	if err != nil || string(pt) != "secret" {
+		t.Fatalf("DecryptWithContext = %q, %v", pt, err)
+	}
+
+	if _, err := c.DecryptWithContext(ct, []byte("email:u2")); err == nil {
+		t.Error("decrypt with a different context should fail")
+	}
+	if _, err := c.Decrypt(ct); err == nil {
+		t.Error("decrypt without context should fail")
+	}
+}
+
+func TestZero(t *testing.T) {
+	b := []byte("secret")
+	Zero(b)
+	if !bytes.Equal(b, make([]byte, 6)) {
+		t.Errorf("Zero left %q", b)
+	}
+}
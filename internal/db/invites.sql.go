@@ -55,6 +55,18 @@ func (q *Queries) GetInviteByTokenHash(ctx context.Context, tokenHash string) (I
 	return i, err
 }
 
+const invalidatePendingInvites = `-- name: InvalidatePendingInvites :execrows
+UPDATE invitation_tokens SET used = TRUE WHERE used = FALSE
+`
+
+func (q *Queries) InvalidatePendingInvites(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, invalidatePendingInvites)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const markInviteUsed = `-- name: MarkInviteUsed :exec
 UPDATE invitation_tokens SET used = TRUE WHERE id = ?
 `
@@ -0,0 +1,3 @@
+-- Digests already rewritten are not reverted; rolling back past this point
+-- breaks email login until the old HMAC scheme is restored in code.
+DROP TABLE IF EXISTS email_hmac_pending;
@@ -0,0 +1,8 @@
+-- Email HMACs are now computed over "email:" || address. The key needed to
+-- recompute them isn't available to SQL, so queue every existing user and let
+-- UserStore.UpgradeEmailHMACs rewrite their digests at startup.
+CREATE TABLE IF NOT EXISTS email_hmac_pending (
+    user_id TEXT PRIMARY KEY REFERENCES admin_users(id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO email_hmac_pending (user_id) SELECT id FROM admin_users;
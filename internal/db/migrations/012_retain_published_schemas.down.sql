@@ -0,0 +1,2 @@
+DELETE FROM report_schema WHERE retired = 1;
+ALTER TABLE report_schema DROP COLUMN retired;
@@ -0,0 +1,4 @@
+-- Keep every schema that was ever live instead of discarding it on the next
+-- publish. Retired rows are neither live nor the draft; they only feed the
+-- publish history. Schemas replaced before this migration are already gone.
+ALTER TABLE report_schema ADD COLUMN retired INTEGER NOT NULL DEFAULT 0;
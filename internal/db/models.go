@@ -60,6 +60,7 @@ type ReportSchema struct {
 	Schema    json.RawMessage `json:"schema"`
 	UpdatedAt string          `json:"updated_at"`
 	UpdatedBy sql.NullString  `json:"updated_by"`
+	Retired   int64           `json:"retired"`
 }
 
 type Session struct {
@@ -20,15 +20,21 @@ type Querier interface {
 	CreateInvite(ctx context.Context, arg CreateInviteParams) error
 	CreateSession(ctx context.Context, arg CreateSessionParams) error
 	DeleteAdminUser(ctx context.Context, id string) error
+	DeleteAllSessions(ctx context.Context) (int64, error)
 	DeleteDraftSchemas(ctx context.Context) error
 	DeleteExpiredSessions(ctx context.Context) error
 	DeleteSessionsByUserID(ctx context.Context, userID string) error
 	DemoteLiveSchemas(ctx context.Context) error
+	ExtendSession(ctx context.Context, arg ExtendSessionParams) (int64, error)
 	GetAdminUserByEmailHMAC(ctx context.Context, emailHmac string) (GetAdminUserByEmailHMACRow, error)
 	GetAdminUserByID(ctx context.Context, id string) (GetAdminUserByIDRow, error)
 	GetAdminUserByUsername(ctx context.Context, username string) (GetAdminUserByUsernameRow, error)
 	GetAdminUserEmailEncryptedByID(ctx context.Context, id string) ([]byte, error)
 	GetAdminUserRoleByID(ctx context.Context, id string) (string, error)
+	GetDraftRevision(ctx context.Context) (int64, error)
+	GetLiveRevision(ctx context.Context) (int64, error)
+	GetLiveSchema(ctx context.Context) (GetLiveSchemaRow, error)
+	GetLiveSchemaStamp(ctx context.Context) (GetLiveSchemaStampRow, error)
 	GetInviteByTokenHash(ctx context.Context, tokenHash string) (InvitationToken, error)
 	// -- name: GetReportSchema :one
 	// SELECT schema FROM report_schema
@@ -59,16 +65,23 @@ type Querier interface {
 	//     LIMIT 1
 	// );
 	GetReportSchema(ctx context.Context, isLive int64) (json.RawMessage, error)
+	GetRetiredSchema(ctx context.Context, id int64) (GetRetiredSchemaRow, error)
+	GetSessionTimes(ctx context.Context, id string) (GetSessionTimesRow, error)
 	GetSessionUserID(ctx context.Context, id string) (string, error)
 	GetSettings(ctx context.Context) ([]byte, error)
 	InsertDraftSchema(ctx context.Context, arg InsertDraftSchemaParams) error
+	InsertAuditLog(ctx context.Context, arg InsertAuditLogParams) error
 	InsertReportEvent(ctx context.Context, fieldsFilled string) error
+	InvalidatePendingInvites(ctx context.Context) (int64, error)
 	LatestReportEventTime(ctx context.Context) (string, error)
 	ListAdminUsers(ctx context.Context) ([]ListAdminUsersRow, error)
+	ListSchemaHistory(ctx context.Context) ([]ListSchemaHistoryRow, error)
 	MarkInviteUsed(ctx context.Context, id string) error
 	PromoteLatestDraft(ctx context.Context, updatedBy sql.NullString) error
+	SeedLiveSchema(ctx context.Context, arg SeedLiveSchemaParams) (int64, error)
 	ReportEventsByDay(ctx context.Context, submittedAt string) ([]ReportEventsByDayRow, error)
 	SetMustChangePassword(ctx context.Context, arg SetMustChangePasswordParams) error
+	UpdateAdminUserEmail(ctx context.Context, arg UpdateAdminUserEmailParams) (int64, error)
 	UpdateAdminUserLastLogin(ctx context.Context, id string) error
 	UpdateAdminUserPassword(ctx context.Context, arg UpdateAdminUserPasswordParams) error
 	UpdateAdminUserRoleAndStatus(ctx context.Context, arg UpdateAdminUserRoleAndStatusParams) error
@@ -0,0 +1,2 @@
+-- name: InsertAuditLog :exec
+INSERT INTO audit_log (user_id, action, detail) VALUES (?, ?, ?);
@@ -11,3 +11,6 @@ WHERE token_hash = ?
 
 -- name: MarkInviteUsed :exec
 UPDATE invitation_tokens SET used = TRUE WHERE id = ?;
+
+-- name: InvalidatePendingInvites :execrows
+UPDATE invitation_tokens SET used = TRUE WHERE used = FALSE;
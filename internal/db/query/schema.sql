@@ -29,7 +29,7 @@
 
 -- name: GetReportSchema :one
 SELECT schema FROM report_schema
-WHERE is_live = ?
+WHERE is_live = ? AND retired = 0
 ORDER BY id DESC
 LIMIT 1;
 
@@ -37,21 +37,62 @@ LIMIT 1;
 SELECT COUNT(*) FROM report_schema;
 
 -- name: DeleteDraftSchemas :exec
-DELETE FROM report_schema WHERE is_live = 0;
+DELETE FROM report_schema WHERE is_live = 0 AND retired = 0;
+
+-- name: GetDraftRevision :one
+SELECT id FROM report_schema
+WHERE is_live = 0 AND retired = 0
+ORDER BY id DESC
+LIMIT 1;
 
 -- name: InsertDraftSchema :exec
 INSERT INTO report_schema (version, is_live, schema, updated_at, updated_by)
 VALUES (:version, 0, :schema_data, CURRENT_TIMESTAMP, :updated_by);
 
 -- name: DemoteLiveSchemas :exec
-UPDATE report_schema SET is_live = 0 WHERE is_live = 1;
+UPDATE report_schema SET is_live = 0, retired = 1 WHERE is_live = 1;
 
 -- name: PromoteLatestDraft :exec
 UPDATE report_schema
 SET is_live = 1, updated_by = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = (
     SELECT id FROM report_schema
-    WHERE is_live = 0
+    WHERE is_live = 0 AND retired = 0
     ORDER BY id DESC
     LIMIT 1
 );
+
+-- name: ListSchemaHistory :many
+SELECT rs.id, rs.version, rs.is_live, rs.updated_at,
+       COALESCE(u.username, rs.updated_by, '') AS published_by
+FROM report_schema rs
+LEFT JOIN admin_users u ON u.id = rs.updated_by
+WHERE rs.is_live = 1 OR rs.retired = 1
+ORDER BY rs.id DESC;
+
+-- name: GetRetiredSchema :one
+SELECT version, schema FROM report_schema
+WHERE id = ? AND retired = 1;
+
+-- name: SeedLiveSchema :execrows
+INSERT INTO report_schema (version, is_live, schema, updated_at, updated_by)
+SELECT :version, 1, :schema_data, CURRENT_TIMESTAMP, :updated_by
+WHERE NOT EXISTS (SELECT 1 FROM report_schema);
+
+-- name: GetLiveRevision :one
+SELECT id FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetLiveSchema :one
+SELECT id, schema FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetLiveSchemaStamp :one
+SELECT id, updated_at FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1;
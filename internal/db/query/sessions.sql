@@ -10,3 +10,14 @@ DELETE FROM sessions WHERE user_id = ?;
 
 -- name: DeleteExpiredSessions :exec
 DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP;
+
+-- name: DeleteAllSessions :execrows
+DELETE FROM sessions;
+
+-- name: GetSessionTimes :one
+SELECT created_at, expires_at FROM sessions
+WHERE id = ? AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: ExtendSession :execrows
+UPDATE sessions SET expires_at = ?
+WHERE id = ? AND expires_at > CURRENT_TIMESTAMP;
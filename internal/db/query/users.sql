@@ -28,6 +28,9 @@ ORDER BY created_at;
 -- name: UpdateAdminUserRoleAndStatus :exec
 UPDATE admin_users SET role = ?, status = ? WHERE id = ?;
 
+-- name: UpdateAdminUserEmail :execrows
+UPDATE admin_users SET email_hmac = ?, email_encrypted = ? WHERE id = ?;
+
 -- name: UpdateAdminUserPassword :exec
 UPDATE admin_users SET password_hash = ? WHERE id = ?;
 
@@ -23,7 +23,7 @@ func (q *Queries) CountReportSchemas(ctx context.Context) (int64, error) {
 }
 
 const deleteDraftSchemas = `-- name: DeleteDraftSchemas :exec
-DELETE FROM report_schema WHERE is_live = 0
+DELETE FROM report_schema WHERE is_live = 0 AND retired = 0
 `
 
 func (q *Queries) DeleteDraftSchemas(ctx context.Context) error {
@@ -32,7 +32,7 @@ func (q *Queries) DeleteDraftSchemas(ctx context.Context) error {
 }
 
 const demoteLiveSchemas = `-- name: DemoteLiveSchemas :exec
-UPDATE report_schema SET is_live = 0 WHERE is_live = 1
+UPDATE report_schema SET is_live = 0, retired = 1 WHERE is_live = 1
 `
 
 func (q *Queries) DemoteLiveSchemas(ctx context.Context) error {
@@ -40,10 +40,93 @@ func (q *Queries) DemoteLiveSchemas(ctx context.Context) error {
 	return err
 }
 
+const getDraftRevision = `-- name: GetDraftRevision :one
+SELECT id FROM report_schema
+WHERE is_live = 0 AND retired = 0
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetDraftRevision(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getDraftRevision)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getRetiredSchema = `-- name: GetRetiredSchema :one
+SELECT version, schema FROM report_schema
+WHERE id = ? AND retired = 1
+`
+
+type GetRetiredSchemaRow struct {
+	Version int64           `json:"version"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+func (q *Queries) GetRetiredSchema(ctx context.Context, id int64) (GetRetiredSchemaRow, error) {
+	row := q.db.QueryRowContext(ctx, getRetiredSchema, id)
+	var i GetRetiredSchemaRow
+	err := row.Scan(&i.Version, &i.Schema)
+	return i, err
+}
+
+const getLiveRevision = `-- name: GetLiveRevision :one
+SELECT id FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLiveRevision(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getLiveRevision)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getLiveSchema = `-- name: GetLiveSchema :one
+SELECT id, schema FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLiveSchemaRow struct {
+	ID     int64           `json:"id"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+func (q *Queries) GetLiveSchema(ctx context.Context) (GetLiveSchemaRow, error) {
+	row := q.db.QueryRowContext(ctx, getLiveSchema)
+	var i GetLiveSchemaRow
+	err := row.Scan(&i.ID, &i.Schema)
+	return i, err
+}
+
+const getLiveSchemaStamp = `-- name: GetLiveSchemaStamp :one
+SELECT id, updated_at FROM report_schema
+WHERE is_live = 1
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLiveSchemaStampRow struct {
+	ID        int64  `json:"id"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func (q *Queries) GetLiveSchemaStamp(ctx context.Context) (GetLiveSchemaStampRow, error) {
+	row := q.db.QueryRowContext(ctx, getLiveSchemaStamp)
+	var i GetLiveSchemaStampRow
+	err := row.Scan(&i.ID, &i.UpdatedAt)
+	return i, err
+}
+
 const getReportSchema = `-- name: GetReportSchema :one
 
 SELECT schema FROM report_schema
-WHERE is_live = ?
+WHERE is_live = ? AND retired = 0
 ORDER BY id DESC
 LIMIT 1
 `
@@ -101,12 +184,58 @@ func (q *Queries) InsertDraftSchema(ctx context.Context, arg InsertDraftSchemaPa
 	return err
 }
 
+const listSchemaHistory = `-- name: ListSchemaHistory :many
+SELECT rs.id, rs.version, rs.is_live, rs.updated_at,
+       COALESCE(u.username, rs.updated_by, '') AS published_by
+FROM report_schema rs
+LEFT JOIN admin_users u ON u.id = rs.updated_by
+WHERE rs.is_live = 1 OR rs.retired = 1
+ORDER BY rs.id DESC
+`
+
+type ListSchemaHistoryRow struct {
+	ID          int64  `json:"id"`
+	Version     int64  `json:"version"`
+	IsLive      int64  `json:"is_live"`
+	UpdatedAt   string `json:"updated_at"`
+	PublishedBy string `json:"published_by"`
+}
+
+func (q *Queries) ListSchemaHistory(ctx context.Context) ([]ListSchemaHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSchemaHistory)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSchemaHistoryRow{}
+	for rows.Next() {
+		var i ListSchemaHistoryRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.IsLive,
+			&i.UpdatedAt,
+			&i.PublishedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const promoteLatestDraft = `-- name: PromoteLatestDraft :exec
 UPDATE report_schema
 SET is_live = 1, updated_by = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = (
     SELECT id FROM report_schema
-    WHERE is_live = 0
+    WHERE is_live = 0 AND retired = 0
     ORDER BY id DESC
     LIMIT 1
 )
@@ -116,3 +245,23 @@ func (q *Queries) PromoteLatestDraft(ctx context.Context, updatedBy sql.NullStri
 	_, err := q.db.ExecContext(ctx, promoteLatestDraft, updatedBy)
 	return err
 }
+
+const seedLiveSchema = `-- name: SeedLiveSchema :execrows
+INSERT INTO report_schema (version, is_live, schema, updated_at, updated_by)
+SELECT ?1, 1, ?2, CURRENT_TIMESTAMP, ?3
+WHERE NOT EXISTS (SELECT 1 FROM report_schema)
+`
+
+type SeedLiveSchemaParams struct {
+	Version    int64           `json:"version"`
+	SchemaData json.RawMessage `json:"schema_data"`
+	UpdatedBy  sql.NullString  `json:"updated_by"`
+}
+
+func (q *Queries) SeedLiveSchema(ctx context.Context, arg SeedLiveSchemaParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, seedLiveSchema, arg.Version, arg.SchemaData, arg.UpdatedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -24,6 +24,18 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) er
 	return err
 }
 
+const deleteAllSessions = `-- name: DeleteAllSessions :execrows
+DELETE FROM sessions
+`
+
+func (q *Queries) DeleteAllSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAllSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
 DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP
 `
@@ -42,6 +54,41 @@ func (q *Queries) DeleteSessionsByUserID(ctx context.Context, userID string) err
 	return err
 }
 
+const extendSession = `-- name: ExtendSession :execrows
+UPDATE sessions SET expires_at = ?
+WHERE id = ? AND expires_at > CURRENT_TIMESTAMP
+`
+
+type ExtendSessionParams struct {
+	ExpiresAt string `json:"expires_at"`
+	ID        string `json:"id"`
+}
+
+func (q *Queries) ExtendSession(ctx context.Context, arg ExtendSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, extendSession, arg.ExpiresAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getSessionTimes = `-- name: GetSessionTimes :one
+SELECT created_at, expires_at FROM sessions
+WHERE id = ? AND expires_at > CURRENT_TIMESTAMP
+`
+
+type GetSessionTimesRow struct {
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func (q *Queries) GetSessionTimes(ctx context.Context, id string) (GetSessionTimesRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionTimes, id)
+	var i GetSessionTimesRow
+	err := row.Scan(&i.CreatedAt, &i.ExpiresAt)
+	return i, err
+}
+
 const getSessionUserID = `-- name: GetSessionUserID :one
 SELECT user_id FROM sessions
 WHERE id = ? AND expires_at > CURRENT_TIMESTAMP
@@ -254,6 +254,24 @@ func (q *Queries) SetMustChangePassword(ctx context.Context, arg SetMustChangePa
 	return err
 }
 
+const updateAdminUserEmail = `-- name: UpdateAdminUserEmail :execrows
+UPDATE admin_users SET email_hmac = ?, email_encrypted = ? WHERE id = ?
+`
+
+type UpdateAdminUserEmailParams struct {
+	EmailHmac      string `json:"email_hmac"`
+	EmailEncrypted []byte `json:"email_encrypted"`
+	ID             string `json:"id"`
+}
+
+func (q *Queries) UpdateAdminUserEmail(ctx context.Context, arg UpdateAdminUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateAdminUserEmail, arg.EmailHmac, arg.EmailEncrypted, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateAdminUserLastLogin = `-- name: UpdateAdminUserLastLogin :exec
 UPDATE admin_users SET last_login_at = CURRENT_TIMESTAMP WHERE id = ?
 `
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// activityWindow is how far back the submission counter remembers, kept in
+// one-minute buckets so "last hour" is exact to the minute.
+const activityWindow = 24 * time.Hour
+
+// ActivityCounter keeps rolling in-memory submission counts for the admin
+// dashboard. It holds nothing but per-minute totals and per-language totals:
+// no report content, no IPs, no exact times. Counts reset on restart.
+type ActivityCounter struct {
+	mu      sync.Mutex
+	buckets []activityBucket // ring indexed by minute
+	now     func() time.Time
+}
+
+type activityBucket struct {
+	minute int64 // Unix minute this bucket holds, 0 if never used
+	total  int
+	byLang map[string]int
+}
+
+// ActivitySnapshot is the admin view of recent submission volume.
+type ActivitySnapshot struct {
+	LastHour ActivityTotals `json:"last_hour"`
+	Last24h  ActivityTotals `json:"last_24h"`
+	// Hourly holds 24 counts, oldest first; the last entry is the current,
+	// partial hour.
+	Hourly []int `json:"hourly"`
+}
+
+// ActivityTotals is a submission count with its per-language breakdown.
+type ActivityTotals struct {
+	Total  int            `json:"total"`
+	ByLang map[string]int `json:"by_lang"`
+}
+
+func NewActivityCounter() *ActivityCounter {
+	return &ActivityCounter{buckets: make([]activityBucket, int(activityWindow/time.Minute)), now: time.Now}
+}
+
+// Record counts one accepted submission in the given form language.
+func (c *ActivityCounter) Record(lang string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	minute := c.now().Unix() / 60
+	b := &c.buckets[minute%int64(len(c.buckets))]
+	if b.minute != minute {
+		*b = activityBucket{minute: minute, byLang: make(map[string]int)}
+	}
+	b.total++
+	b.byLang[lang]++
+}
+
+// Snapshot sums the buckets that are still inside the window.
+func (c *ActivityCounter) Snapshot() ActivitySnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now().Unix() / 60
+	s := ActivitySnapshot{
+		LastHour: ActivityTotals{ByLang: map[string]int{}},
+		Last24h:  ActivityTotals{ByLang: map[string]int{}},
+		Hourly:   make([]int, 24),
+	}
+	for _, b := range c.buckets {
+		age := now - b.minute
+		if b.total == 0 || age < 0 || age >= int64(len(c.buckets)) {
+			continue
+		}
+		addTotals(&s.Last24h, b)
+		if age < 60 {
+			addTotals(&s.LastHour, b)
+		}
+		s.Hourly[23-age/60] += b.total
+	}
+	return s
+}
+
+func addTotals(t *ActivityTotals, b activityBucket) {
+	t.Total += b.total
+	for lang, n := range b.byLang {
+		t.ByLang[lang] += n
+	}
+}
+
+// Activity returns recent submission volume as JSON.
+func (h *StatsHandler) Activity(w http.ResponseWriter, r *http.Request) {
+	if err := h.writeJSON(w, http.StatusOK, envelope{"activity": h.activity.Snapshot()}, nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
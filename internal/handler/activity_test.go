@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivityCounterWindows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	c := NewActivityCounter()
+	c.now = func() time.Time { return now }
+
+	record := func(ago time.Duration, lang string) {
+		c.now = func() time.Time { return now.Add(-ago) }
+		c.Record(lang)
+	}
+	record(25*time.Hour, "en") // outside the window
+	record(5*time.Hour, "es")
+	record(10*time.Minute, "en")
+	record(time.Minute, "en")
+	c.now = func() time.Time { return now }
+
+	s := c.Snapshot()
+	if s.LastHour.Total != 2 || s.LastHour.ByLang["en"] != 2 {
+		t.Errorf("last hour = %+v, want 2 en", s.LastHour)
+	}
+	if s.Last24h.Total != 3 || s.Last24h.ByLang["es"] != 1 {
+		t.Errorf("last 24h = %+v, want 3 with 1 es", s.Last24h)
+	}
+	if s.Hourly[23] != 2 || s.Hourly[18] != 1 {
+		t.Errorf("hourly = %v, want 2 in the current hour and 1 five hours back", s.Hourly)
+	}
+}
+
+func TestActivityCounterReusesStaleBuckets(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewActivityCounter()
+	c.now = func() time.Time { return now }
+	c.Record("en")
+
+	// Exactly one window later the same ring slot is reused.
+	now = now.Add(activityWindow)
+	c.Record("fr")
+
+	s := c.Snapshot()
+	if s.Last24h.Total != 1 || s.Last24h.ByLang["fr"] != 1 {
+		t.Errorf("last 24h = %+v, want only the new fr submission", s.Last24h)
+	}
+}
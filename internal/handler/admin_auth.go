@@ -3,9 +3,11 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/mail"
 	"strings"
 	"time"
 
@@ -17,11 +19,12 @@ import (
 
 type userGetterByIdentifier interface {
 	GetByUsername(ctx context.Context, username string) (*model.AdminUser, string, error)
-	GetByEmailHMAC(ctx context.Context, email string) (*model.AdminUser, string, error)
+	GetByEmail(ctx context.Context, email string) (*model.AdminUser, string, error)
 	UpdateLastLogin(ctx context.Context, id string) error
 	UpdatePassword(ctx context.Context, id, hash string) error
 	SetMustChangePassword(ctx context.Context, id string, v bool) error
 	GetPasswordHashByID(ctx context.Context, id string) (string, error)
+	UpdateEmail(ctx context.Context, id, email string) error
 }
 
 type sessionCreatorDeleter interface {
@@ -32,18 +35,36 @@ type sessionCreatorDeleter interface {
 type inviteStore interface {
 	GetInviteByToken(ctx context.Context, rawToken string) (*model.Invite, error)
 	AcceptInvite(ctx context.Context, inviteID, userID, username, email, passwordHash, role string) error
+	SuggestUsername(ctx context.Context, base string) (string, error)
 }
 
+// The real user store backs both login and invite acceptance.
+var (
+	_ userGetterByIdentifier = (*store.UserStore)(nil)
+	_ inviteStore            = (*store.UserStore)(nil)
+)
+
 type loginPageData struct {
 	Error string
 	Nonce string
 }
 
 type acceptInvitePageData struct {
-	Token string
-	Email string
-	Error string
-	Nonce string
+	Token     string
+	FormToken string
+	Email     string
+	Username  string
+	Error     string
+	Nonce     string
+}
+
+// minInviteFillTime is how long the accept-invite form must be open before
+// it is posted. Picking a username and typing a password twice takes longer;
+// a script claiming a leaked link does not wait.
+const minInviteFillTime = 3 * time.Second
+
+func (h *AuthHandler) inviteFormToken() string {
+	return signTimedToken(h.sessionKeys[0], inviteFormTokenDomain, time.Now())
 }
 
 // AuthHandler handles admin authentication.
@@ -53,17 +74,18 @@ type AuthHandler struct {
 	invites       inviteStore
 	templates     *template.Template
 	secureCookies bool
-	sessionKey    []byte
+	sameSite      http.SameSite
+	sessionKeys   [][]byte // current first; the rest only verify
 }
 
-func NewAuthHandler(users userGetterByIdentifier, sessions sessionCreatorDeleter, invites inviteStore, tmpl *template.Template, secureCookies bool, sessionKey []byte) *AuthHandler {
-	return &AuthHandler{users: users, sessions: sessions, invites: invites, templates: tmpl, secureCookies: secureCookies, sessionKey: sessionKey}
+func NewAuthHandler(users userGetterByIdentifier, sessions sessionCreatorDeleter, invites inviteStore, tmpl *template.Template, secureCookies bool, sameSite http.SameSite, sessionKeys [][]byte) *AuthHandler {
+	return &AuthHandler{users: users, sessions: sessions, invites: invites, templates: tmpl, secureCookies: secureCookies, sameSite: sameSite, sessionKeys: sessionKeys}
 }
 
 // LoginPage renders the admin login form.
 func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	if err := h.templates.ExecuteTemplate(w, "admin_login.html", loginPageData{}); err != nil {
-		slog.Error("auth: template error", "err", err)
+		slog.ErrorContext(r.Context(), "auth: template error", "err", err)
 	}
 }
 
@@ -79,11 +101,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	renderLoginError := func(msg string) {
 		if err := h.templates.ExecuteTemplate(w, "admin_login.html", loginPageData{Error: msg}); err != nil {
-			slog.Error("auth: template error", "err", err)
+			slog.ErrorContext(r.Context(), "auth: template error", "err", err)
 		}
 	}
 
-	// Try username first; fall back to email HMAC lookup if the identifier
+	// Try username first; fall back to email lookup if the identifier
 	// looks like an email address.
 	var user *model.AdminUser
 	var hash string
@@ -91,7 +113,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	user, hash, err = h.users.GetByUsername(r.Context(), identifier)
 	if errors.Is(err, store.ErrNotFound) && strings.Contains(identifier, "@") {
-		user, hash, err = h.users.GetByEmailHMAC(r.Context(), identifier)
+		user, hash, err = h.users.GetByEmail(r.Context(), identifier)
 	}
 
 	if err != nil || !auth.Verify(hash, password) {
@@ -104,6 +126,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if auth.NeedsRehash(hash) {
+		if newHash, err := auth.Hash(password); err != nil {
+			slog.WarnContext(r.Context(), "login: failed to rehash password", "err", err)
+		} else if err := h.users.UpdatePassword(r.Context(), user.ID, newHash); err != nil {
+			slog.WarnContext(r.Context(), "login: failed to store upgraded password hash", "err", err)
+		}
+	}
+
 	sessionID, err := h.sessions.Create(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -114,17 +144,17 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     appmw.SessionCookieName,
-		Value:    appmw.SignCookie(h.sessionKey, sessionID),
-		Path:     "/",
+		Value:    appmw.SignCookie(h.sessionKeys[0], sessionID),
+		Path:     appmw.CookiePath(r.Context()),
 		HttpOnly: true,
 		Secure:   h.secureCookies,
-		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(4 * time.Hour),
+		SameSite: h.sameSite,
+		Expires:  time.Now().Add(store.SessionTTL),
 	})
 
-	dest := "/admin/report"
+	dest := appmw.URL(r.Context(), "/admin/report")
 	if user.MustChangePassword {
-		dest = "/admin/change-password"
+		dest = appmw.URL(r.Context(), "/admin/change-password")
 	}
 	http.Redirect(w, r, dest, http.StatusSeeOther)
 }
@@ -132,7 +162,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // AcceptInvitePage renders the accept-invite page for the given token.
 func (h *AuthHandler) AcceptInvitePage(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
-	data := acceptInvitePageData{Token: token}
+	data := acceptInvitePageData{Token: token, FormToken: h.inviteFormToken()}
 
 	if token != "" {
 		invite, err := h.invites.GetInviteByToken(r.Context(), token)
@@ -147,7 +177,7 @@ func (h *AuthHandler) AcceptInvitePage(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, "accept_invite.html", data); err != nil {
-		slog.Error("auth: template error", "err", err)
+		slog.ErrorContext(r.Context(), "auth: template error", "err", err)
 	}
 }
 
@@ -166,12 +196,20 @@ func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(http.StatusBadRequest)
 		_ = h.templates.ExecuteTemplate(w, "accept_invite.html", acceptInvitePageData{
-			Token: token,
-			Email: email,
-			Error: msg,
+			Token:     token,
+			FormToken: h.inviteFormToken(),
+			Email:     email,
+			Error:     msg,
 		})
 	}
 
+	// Reject a post with a missing or forged form token, or one sent too
+	// soon after the page was rendered, before the invite is looked up.
+	if age, ok := timedTokenAge(h.sessionKeys, inviteFormTokenDomain, r.FormValue("_t"), time.Now()); !ok || age < minInviteFillTime {
+		renderError("", "Something went wrong. Please try again.")
+		return
+	}
+
 	if username == "" || strings.ContainsAny(username, " \t\n\r") {
 		renderError("", "Username must not be empty or contain spaces.")
 		return
@@ -195,42 +233,59 @@ func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 			renderError("", "This invitation link is invalid or has expired.")
 			return
 		}
-		slog.Error("accept-invite: lookup failed", "err", err)
+		slog.ErrorContext(r.Context(), "accept-invite: lookup failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	hash, err := auth.Hash(password)
 	if err != nil {
-		slog.Error("accept-invite: hash failed", "err", err)
+		slog.ErrorContext(r.Context(), "accept-invite: hash failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	newUserID := auth.NewID()
 	if err := h.invites.AcceptInvite(r.Context(), invite.ID, newUserID, username, invite.Email, hash, string(invite.Role)); err != nil {
-		slog.Error("accept-invite: accept failed", "err", err)
+		if errors.Is(err, store.ErrUsernameTaken) {
+			msg := "That username is already taken. Please choose another."
+			suggestion, serr := h.invites.SuggestUsername(r.Context(), username)
+			if serr == nil {
+				msg = fmt.Sprintf("That username is already taken. How about %q?", suggestion)
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusConflict)
+			_ = h.templates.ExecuteTemplate(w, "accept_invite.html", acceptInvitePageData{
+				Token:     token,
+				FormToken: h.inviteFormToken(),
+				Email:     invite.Email,
+				Username:  suggestion,
+				Error:     msg,
+			})
+			return
+		}
+		slog.ErrorContext(r.Context(), "accept-invite: accept failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	sessionID, err := h.sessions.Create(r.Context(), newUserID)
 	if err != nil {
-		slog.Error("accept-invite: session create failed", "err", err)
+		slog.ErrorContext(r.Context(), "accept-invite: session create failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     appmw.SessionCookieName,
-		Value:    appmw.SignCookie(h.sessionKey, sessionID),
-		Path:     "/",
+		Value:    appmw.SignCookie(h.sessionKeys[0], sessionID),
+		Path:     appmw.CookiePath(r.Context()),
 		HttpOnly: true,
 		Secure:   h.secureCookies,
-		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(60 * time.Minute),
+		SameSite: h.sameSite,
+		Expires:  time.Now().Add(store.SessionTTL),
 	})
-	http.Redirect(w, r, "/admin/report", http.StatusSeeOther)
+	http.Redirect(w, r, appmw.URL(r.Context(), "/admin/report"), http.StatusSeeOther)
 }
 
 // Logout invalidates all sessions for the authenticated user.
@@ -242,14 +297,14 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     appmw.SessionCookieName,
 		Value:    "",
-		Path:     "/",
+		Path:     appmw.CookiePath(r.Context()),
 		MaxAge:   -1,
 		Expires:  time.Unix(0, 0),
 		HttpOnly: true,
 		Secure:   h.secureCookies,
-		SameSite: http.SameSiteStrictMode,
+		SameSite: h.sameSite,
 	})
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, appmw.URL(r.Context(), "/"), http.StatusSeeOther)
 }
 
 type changePasswordPageData struct {
@@ -261,7 +316,7 @@ type changePasswordPageData struct {
 func (h *AuthHandler) ChangePasswordPage(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, "change_password.html", changePasswordPageData{}); err != nil {
-		slog.Error("auth: change-password template error", "err", err)
+		slog.ErrorContext(r.Context(), "auth: change-password template error", "err", err)
 	}
 }
 
@@ -301,20 +356,96 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 
 	newHash, err := auth.Hash(newPassword)
 	if err != nil {
-		slog.Error("change-password: hash failed", "err", err)
+		slog.ErrorContext(r.Context(), "change-password: hash failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	if err := h.users.UpdatePassword(r.Context(), userID, newHash); err != nil {
-		slog.Error("change-password: update failed", "err", err)
+		slog.ErrorContext(r.Context(), "change-password: update failed", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	if err := h.users.SetMustChangePassword(r.Context(), userID, false); err != nil {
-		slog.Error("change-password: clear flag failed", "err", err)
+		slog.ErrorContext(r.Context(), "change-password: clear flag failed", "err", err)
+	}
+
+	http.Redirect(w, r, appmw.URL(r.Context(), "/admin/report"), http.StatusSeeOther)
+}
+
+type changeEmailPageData struct {
+	Email   string
+	Error   string
+	Success bool
+}
+
+// ChangeEmailPage renders the form for changing the signed-in admin's email.
+func (h *AuthHandler) ChangeEmailPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "change_email.html", changeEmailPageData{}); err != nil {
+		slog.ErrorContext(r.Context(), "auth: change-email template error", "err", err)
+	}
+}
+
+// ChangeEmail updates the signed-in admin's email after re-checking their
+// password. Every other session for the account is signed out, and this one
+// is replaced with a fresh session.
+func (h *AuthHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
 	}
 
-	http.Redirect(w, r, "/admin/report", http.StatusSeeOther)
+	email := strings.TrimSpace(r.FormValue("new_email"))
+	password := r.FormValue("password")
+
+	render := func(status int, data changeEmailPageData) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_ = h.templates.ExecuteTemplate(w, "change_email.html", data)
+	}
+
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		render(http.StatusBadRequest, changeEmailPageData{Email: email, Error: "Enter a valid email address."})
+		return
+	}
+
+	userID := appmw.UserIDFromContext(r.Context())
+
+	hash, err := h.users.GetPasswordHashByID(r.Context(), userID)
+	if err != nil || !auth.Verify(hash, password) {
+		render(http.StatusBadRequest, changeEmailPageData{Email: email, Error: "Password is incorrect."})
+		return
+	}
+
+	if err := h.users.UpdateEmail(r.Context(), userID, email); errors.Is(err, store.ErrEmailTaken) {
+		render(http.StatusConflict, changeEmailPageData{Email: email, Error: "That email address is already in use."})
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "change-email: update failed", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sessions.DeleteAllByUserID(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "change-email: failed to revoke sessions", "err", err)
+	}
+	sessionID, err := h.sessions.Create(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "change-email: failed to create session", "err", err)
+		http.Redirect(w, r, appmw.URL(r.Context(), "/admin/login"), http.StatusSeeOther)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     appmw.SessionCookieName,
+		Value:    appmw.SignCookie(h.sessionKeys[0], sessionID),
+		Path:     appmw.CookiePath(r.Context()),
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: h.sameSite,
+		Expires:  time.Now().Add(store.SessionTTL),
+	})
+
+	render(http.StatusOK, changeEmailPageData{Success: true})
 }
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firewatch/internal/auth"
+	appmw "github.com/firewatch/internal/middleware"
+	"github.com/firewatch/internal/model"
+	"github.com/firewatch/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeLoginUsers struct {
+	user  *model.AdminUser
+	hash  string
+	email string
+	taken string // UpdateEmail reports this address as another user's
+}
+
+func (f *fakeLoginUsers) GetByUsername(ctx context.Context, username string) (*model.AdminUser, string, error) {
+	if username != f.user.Username {
+		return nil, "", store.ErrNotFound
+	}
+	return f.user, f.hash, nil
+}
+
+func (f *fakeLoginUsers) GetByEmail(ctx context.Context, email string) (*model.AdminUser, string, error) {
+	return nil, "", store.ErrNotFound
+}
+
+func (f *fakeLoginUsers) UpdateLastLogin(ctx context.Context, id string) error { return nil }
+
+func (f *fakeLoginUsers) UpdatePassword(ctx context.Context, id, hash string) error {
+	f.hash = hash
+	return nil
+}
+
+func (f *fakeLoginUsers) SetMustChangePassword(ctx context.Context, id string, v bool) error {
+	return nil
+}
+
+func (f *fakeLoginUsers) GetPasswordHashByID(ctx context.Context, id string) (string, error) {
+	return f.hash, nil
+}
+
+func (f *fakeLoginUsers) UpdateEmail(ctx context.Context, id, email string) error {
+	if email == f.taken {
+		return store.ErrEmailTaken
+	}
+	f.email = email
+	return nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) Create(ctx context.Context, userID string) (string, error)  { return "sess", nil }
+func (fakeSessions) DeleteAllByUserID(ctx context.Context, userID string) error { return nil }
+
+func TestLoginUpgradesLowCostHash(t *testing.T) {
+	if err := auth.SetCost(bcrypt.MinCost + 1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = auth.SetCost(auth.DefaultBcryptCost) })
+
+	old, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &fakeLoginUsers{
+		user: &model.AdminUser{ID: "u1", Username: "alice", Status: model.StatusActive},
+		hash: string(old),
+	}
+	h := NewAuthHandler(users, fakeSessions{}, nil, nil, false, http.SameSiteStrictMode, [][]byte{make([]byte, 32)})
+
+	form := url.Values{"identifier": {"alice"}, "password": {"correct-horse-battery"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	cost, err := bcrypt.Cost([]byte(users.hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.MinCost+1 {
+		t.Errorf("stored hash cost = %d, want %d", cost, bcrypt.MinCost+1)
+	}
+	if !auth.Verify(users.hash, "correct-horse-battery") {
+		t.Error("upgraded hash does not verify")
+	}
+}
+
+type recordingSessions struct {
+	deleted []string
+	created []string
+}
+
+func (s *recordingSessions) Create(ctx context.Context, userID string) (string, error) {
+	s.created = append(s.created, userID)
+	return "fresh", nil
+}
+
+func (s *recordingSessions) DeleteAllByUserID(ctx context.Context, userID string) error {
+	s.deleted = append(s.deleted, userID)
+	return nil
+}
+
+func TestChangeEmail(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("change_email.html").Parse("{{.Error}}"))
+
+	post := func(users *fakeLoginUsers, sessions *recordingSessions, email, password string) *httptest.ResponseRecorder {
+		h := NewAuthHandler(users, sessions, nil, tmpl, false, http.SameSiteStrictMode, [][]byte{make([]byte, 32)})
+		form := url.Values{"new_email": {email}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/change-email", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ChangeEmail(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name, email, password string
+		wantStatus            int
+	}{
+		{"wrong password", "new@example.org", "wrong", http.StatusBadRequest},
+		{"invalid email", "not an email", "correct-horse-battery", http.StatusBadRequest},
+		{"taken", "bob@example.org", "correct-horse-battery", http.StatusConflict},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			users := &fakeLoginUsers{hash: string(hash), taken: "bob@example.org"}
+			sessions := &recordingSessions{}
+			rec := post(users, sessions, tc.email, tc.password)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if users.email != "" || len(sessions.deleted) != 0 {
+				t.Errorf("rejected change still applied: email=%q, revoked=%v", users.email, sessions.deleted)
+			}
+		})
+	}
+
+	users := &fakeLoginUsers{hash: string(hash)}
+	sessions := &recordingSessions{}
+	rec := post(users, sessions, "new@example.org", "correct-horse-battery")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if users.email != "new@example.org" {
+		t.Errorf("email = %q, want new@example.org", users.email)
+	}
+	if len(sessions.deleted) != 1 || len(sessions.created) != 1 {
+		t.Errorf("sessions not rotated: deleted=%v created=%v", sessions.deleted, sessions.created)
+	}
+	if c := rec.Result().Cookies(); len(c) != 1 || c[0].Name != appmw.SessionCookieName {
+		t.Errorf("no fresh session cookie set: %v", c)
+	}
+}
+
+type fakeInvites struct {
+	accepted int
+}
+
+func (f *fakeInvites) GetInviteByToken(ctx context.Context, rawToken string) (*model.Invite, error) {
+	if rawToken != "invite-token" {
+		return nil, store.ErrNotFound
+	}
+	return &model.Invite{ID: "inv1", Email: "new@example.org", Role: model.RoleAdmin}, nil
+}
+
+func (f *fakeInvites) AcceptInvite(ctx context.Context, inviteID, userID, username, email, passwordHash, role string) error {
+	f.accepted++
+	return nil
+}
+
+func (f *fakeInvites) SuggestUsername(ctx context.Context, base string) (string, error) {
+	return base + "1", nil
+}
+
+func TestAcceptInviteRejectsFastPost(t *testing.T) {
+	key := make([]byte, 32)
+	tmpl := template.Must(template.New("accept_invite.html").Parse("{{.Error}}|{{.FormToken}}"))
+
+	post := func(invites *fakeInvites, formToken string) *httptest.ResponseRecorder {
+		h := NewAuthHandler(&fakeLoginUsers{}, fakeSessions{}, invites, tmpl, false, http.SameSiteLaxMode, [][]byte{key})
+		form := url.Values{
+			"token":            {"invite-token"},
+			"_t":               {formToken},
+			"username":         {"newadmin"},
+			"password":         {"correct-horse-battery"},
+			"confirm_password": {"correct-horse-battery"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/accept-invite", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.AcceptInvite(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name, formToken string
+	}{
+		{"missing", ""},
+		{"forged", signFormToken(key, time.Now().Add(-time.Minute))},
+		{"too fast", signTimedToken(key, inviteFormTokenDomain, time.Now().Add(-time.Second))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			invites := &fakeInvites{}
+			rec := post(invites, tc.formToken)
+			if rec.Code != http.StatusBadRequest || invites.accepted != 0 {
+				t.Errorf("status = %d, accepted = %d; want 400 and nothing accepted", rec.Code, invites.accepted)
+			}
+			// The re-rendered form carries a fresh token to try again with.
+			if _, tok, _ := strings.Cut(rec.Body.String(), "|"); tok == "" {
+				t.Error("error page has no fresh form token")
+			}
+		})
+	}
+
+	invites := &fakeInvites{}
+	rec := post(invites, signTimedToken(key, inviteFormTokenDomain, time.Now().Add(-10*time.Second)))
+	if rec.Code != http.StatusSeeOther || invites.accepted != 1 {
+		t.Errorf("status = %d, accepted = %d; want 303 and the invite accepted", rec.Code, invites.accepted)
+	}
+	if c := rec.Result().Cookies(); len(c) != 1 || c[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("session cookie does not use the configured SameSite mode: %v", c)
+	}
+}
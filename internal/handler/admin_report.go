@@ -3,27 +3,36 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
 	appmw "github.com/firewatch/internal/middleware"
 	"github.com/firewatch/internal/model"
+	"github.com/firewatch/internal/store"
 )
 
 type adminReportPageData struct {
 	model.ReportSchema
 	SchemaJSON             template.JS
 	SupportedLanguagesJSON template.JS
+	Revision               int64
 	IsSuperAdmin           bool
 	Nonce                  string
 }
 
 type schemaDraftStore interface {
 	DraftSchema(ctx context.Context) (*model.ReportSchema, error)
-	SaveDraft(ctx context.Context, schema *model.ReportSchema, updatedBy string) error
+	DraftRevision(ctx context.Context) (int64, error)
+	UpdateDraft(ctx context.Context, schema *model.ReportSchema, updatedBy string, revision int64) (int64, error)
 	PromoteDraft(ctx context.Context, updatedBy string) error
 	RevertDraftToLive(ctx context.Context, updatedBy string) error
+	History(ctx context.Context) ([]store.SchemaRevision, error)
+	Rollback(ctx context.Context, id int64, updatedBy string) error
 }
 
 // AdminReportHandler handles the admin form editor views and API.
@@ -41,7 +50,13 @@ func NewAdminReportHandler(logger *slog.Logger, schemas schemaDraftStore, tmpl *
 func (h *AdminReportHandler) Page(w http.ResponseWriter, r *http.Request) {
 	schema, err := h.schemas.DraftSchema(r.Context())
 	if err != nil {
-		slog.Error("admin_report: failed to load draft schema", "err", err)
+		slog.ErrorContext(r.Context(), "admin_report: failed to load draft schema", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	revision, err := h.schemas.DraftRevision(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "admin_report: failed to load draft revision", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -51,61 +66,128 @@ func (h *AdminReportHandler) Page(w http.ResponseWriter, r *http.Request) {
 		ReportSchema:           *schema,
 		SchemaJSON:             template.JS(jsonBytes),
 		SupportedLanguagesJSON: template.JS(langBytes),
+		Revision:               revision,
 		IsSuperAdmin:           appmw.IsSuperAdmin(r.Context()),
 		Nonce:                  appmw.NonceFromContext(r.Context()),
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, "admin_report.html", data); err != nil {
-		slog.Error("admin_report: template error", "err", err)
+		slog.ErrorContext(r.Context(), "admin_report: template error", "err", err)
 	}
 }
 
-// Get returns the current draft schema as JSON.
+// Get returns the current draft schema as JSON, with the revision Update
+// expects back in If-Match.
 func (h *AdminReportHandler) Get(w http.ResponseWriter, r *http.Request) {
 	schema, err := h.schemas.DraftSchema(r.Context())
 	if err != nil {
 		h.serverErrorResponse(w, r, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	revision, err := h.schemas.DraftRevision(r.Context())
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = h.writeJSON(w, http.StatusOK, envelope{"schema": schema}, nil)
+	err = h.writeJSON(w, http.StatusOK, envelope{"schema": schema, "revision": revision}, draftETag(revision))
 	if err != nil {
 		h.serverErrorResponse(w, r, err)
 		return
 	}
 }
 
-// Update saves a draft schema update.
+// Update saves a draft schema update. The client must send the revision it
+// loaded in If-Match; if another admin saved in the meantime the update is
+// rejected with 409 rather than overwriting their changes.
 func (h *AdminReportHandler) Update(w http.ResponseWriter, r *http.Request) {
 	user := appmw.UserIDFromContext(r.Context())
 
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		h.errorResponse(w, r, http.StatusPreconditionRequired, "If-Match header with the draft revision is required")
+		return
+	}
+	revision, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil {
+		h.errorResponse(w, r, http.StatusBadRequest, "If-Match must be a draft revision")
+		return
+	}
+
 	schema := &model.ReportSchema{}
 	if err := h.readJSON(w, r, &schema); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		h.errorResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Always store as v2 so the migration check in load() never fires
-	// on a schema that was saved by this handler.
-	schema.SchemaVersion = 2
+	// The editor always produces the current layout, so stamp it as such;
+	// otherwise load() would try to migrate it on the next read.
+	schema.SchemaVersion = model.CurrentSchemaVersion
 
-	if err := h.schemas.SaveDraft(r.Context(), schema, user); err != nil {
+	revision, err = h.schemas.UpdateDraft(r.Context(), schema, user, revision)
+	if errors.Is(err, store.ErrDraftConflict) {
+		h.errorResponse(w, r, http.StatusConflict, "the draft was changed by someone else; reload to see their changes")
+		return
+	}
+	if err != nil {
 		h.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if err := h.writeJSON(w, http.StatusOK, envelope{"schema": schema}, nil); err != nil {
+	env := envelope{"schema": schema, "revision": revision, "warnings": schema.Warnings()}
+	if err := h.writeJSON(w, http.StatusOK, env, draftETag(revision)); err != nil {
 		h.serverErrorResponse(w, r, err)
 		return
 	}
 }
 
+// History returns who published each live schema and when, newest first.
+func (h *AdminReportHandler) History(w http.ResponseWriter, r *http.Request) {
+	history, err := h.schemas.History(r.Context())
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := h.writeJSON(w, http.StatusOK, envelope{"history": history}, nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// Rollback re-publishes a schema from the publish history.
+func (h *AdminReportHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := h.readJSON(w, r, &req); err != nil {
+		h.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := h.schemas.Rollback(r.Context(), req.ID, appmw.UserIDFromContext(r.Context()))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.errorResponse(w, r, http.StatusNotFound, "no earlier published schema with that id")
+		return
+	case errors.Is(err, store.ErrInvalidSchema):
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// draftETag returns response headers carrying revision as a strong ETag.
+func draftETag(revision int64) http.Header {
+	return http.Header{"Etag": []string{`"` + strconv.FormatInt(revision, 10) + `"`}}
+}
+
 // Revert resets the draft schema to match the current live schema.
 func (h *AdminReportHandler) Revert(w http.ResponseWriter, r *http.Request) {
 	userID := appmw.UserIDFromContext(r.Context())
 	if err := h.schemas.RevertDraftToLive(r.Context(), userID); err != nil {
-		slog.Error("admin_report: failed to revert draft", "err", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.serverErrorResponse(w, r, fmt.Errorf("revert draft: %w", err))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -114,9 +196,13 @@ func (h *AdminReportHandler) Revert(w http.ResponseWriter, r *http.Request) {
 // Apply promotes the draft schema to live.
 func (h *AdminReportHandler) Apply(w http.ResponseWriter, r *http.Request) {
 	userID := appmw.UserIDFromContext(r.Context())
-	if err := h.schemas.PromoteDraft(r.Context(), userID); err != nil {
-		slog.Error("admin_report: failed to promote draft", "err", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	err := h.schemas.PromoteDraft(r.Context(), userID)
+	if errors.Is(err, store.ErrInvalidSchema) {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		h.serverErrorResponse(w, r, fmt.Errorf("promote draft: %w", err))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firewatch/internal/model"
+	"github.com/firewatch/internal/store"
+)
+
+// failingDraftStore fails every call with err.
+type failingDraftStore struct{ err error }
+
+func (f failingDraftStore) DraftSchema(context.Context) (*model.ReportSchema, error) {
+	return nil, f.err
+}
+func (f failingDraftStore) DraftRevision(context.Context) (int64, error) { return 0, f.err }
+func (f failingDraftStore) UpdateDraft(context.Context, *model.ReportSchema, string, int64) (int64, error) {
+	return 0, f.err
+}
+func (f failingDraftStore) PromoteDraft(context.Context, string) error      { return f.err }
+func (f failingDraftStore) RevertDraftToLive(context.Context, string) error { return f.err }
+func (f failingDraftStore) History(context.Context) ([]store.SchemaRevision, error) {
+	return nil, f.err
+}
+func (f failingDraftStore) Rollback(context.Context, int64, string) error { return f.err }
+
+// statusCounter counts WriteHeader calls so a second status write is caught
+// even though httptest.ResponseRecorder silently ignores it.
+type statusCounter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (s *statusCounter) WriteHeader(code int) {
+	s.writes++
+	s.ResponseRecorder.WriteHeader(code)
+}
+
+func TestAdminReportErrorsWriteOnce(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewAdminReportHandler(logger, failingDraftStore{err: errors.New("db down")}, nil)
+
+	for name, fn := range map[string]http.HandlerFunc{
+		"Get":    h.Get,
+		"Revert": h.Revert,
+		"Apply":  h.Apply,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := &statusCounter{ResponseRecorder: httptest.NewRecorder()}
+			fn(rr, httptest.NewRequest(http.MethodPost, "/api/admin/report", nil))
+
+			if rr.writes != 1 {
+				t.Errorf("WriteHeader called %d times, want 1", rr.writes)
+			}
+			assertJSONError(t, rr.ResponseRecorder, http.StatusInternalServerError)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	appmw "github.com/firewatch/internal/middleware"
+	"github.com/firewatch/internal/store"
+)
+
+type sessionLifetime interface {
+	ExpiresAt(ctx context.Context, sessionID string) (time.Time, error)
+	Touch(ctx context.Context, sessionID string) (time.Time, error)
+}
+
+var _ sessionLifetime = (*store.SessionStore)(nil)
+
+// SessionHandler lets the admin UI warn before the session expires and
+// extend it on request.
+type SessionHandler struct {
+	BaseHandler
+	sessions      sessionLifetime
+	warnBefore    time.Duration
+	secureCookies bool
+	sameSite      http.SameSite
+}
+
+func NewSessionHandler(logger *slog.Logger, sessions sessionLifetime, warnBefore time.Duration, secureCookies bool, sameSite http.SameSite) *SessionHandler {
+	return &SessionHandler{BaseHandler: BaseHandler{logger: logger}, sessions: sessions, warnBefore: warnBefore, secureCookies: secureCookies, sameSite: sameSite}
+}
+
+// Status reports when the current session expires. Polling it does not
+// extend the session.
+func (h *SessionHandler) Status(w http.ResponseWriter, r *http.Request) {
+	expiresAt, err := h.sessions.ExpiresAt(r.Context(), appmw.SessionIDFromContext(r.Context()))
+	h.respond(w, r, expiresAt, err)
+}
+
+// Refresh extends the current session and its cookie; see store.SessionStore.Touch
+// for how far.
+func (h *SessionHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	expiresAt, err := h.sessions.Touch(r.Context(), appmw.SessionIDFromContext(r.Context()))
+	if err == nil {
+		if cookie, cerr := r.Cookie(appmw.SessionCookieName); cerr == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     appmw.SessionCookieName,
+				Value:    cookie.Value,
+				Path:     appmw.CookiePath(r.Context()),
+				HttpOnly: true,
+				Secure:   h.secureCookies,
+				SameSite: h.sameSite,
+				Expires:  expiresAt,
+			})
+		}
+	}
+	h.respond(w, r, expiresAt, err)
+}
+
+func (h *SessionHandler) respond(w http.ResponseWriter, r *http.Request, expiresAt time.Time, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		// The session ended between the middleware check and now.
+		h.errorResponse(w, r, http.StatusUnauthorized, "session expired")
+		return
+	}
+	if err != nil {
+		h.serverErrorResponse(w, r, fmt.Errorf("session: %w", err))
+		return
+	}
+
+	remaining := max(time.Until(expiresAt), 0)
+	headers := http.Header{"Cache-Control": []string{"no-store"}}
+	if err := h.writeJSON(w, http.StatusOK, envelope{
+		"expiresAt":         expiresAt.UTC(),
+		"remainingSeconds":  int(remaining.Seconds()),
+		"warnBeforeSeconds": int(h.warnBefore.Seconds()),
+	}, headers); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
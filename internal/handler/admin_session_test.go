@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appmw "github.com/firewatch/internal/middleware"
+	"github.com/firewatch/internal/store"
+)
+
+type fakeSessionLifetime struct {
+	expires time.Time
+	touched int
+	err     error
+}
+
+func (f *fakeSessionLifetime) ExpiresAt(ctx context.Context, sessionID string) (time.Time, error) {
+	return f.expires, f.err
+}
+
+func (f *fakeSessionLifetime) Touch(ctx context.Context, sessionID string) (time.Time, error) {
+	if f.err != nil {
+		return time.Time{}, f.err
+	}
+	f.touched++
+	f.expires = time.Now().Add(time.Hour)
+	return f.expires, nil
+}
+
+func TestSessionStatusAndRefresh(t *testing.T) {
+	sessions := &fakeSessionLifetime{expires: time.Now().Add(3 * time.Minute)}
+	h := NewSessionHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), sessions, 5*time.Minute, true, http.SameSiteStrictMode)
+
+	decode := func(rec *httptest.ResponseRecorder) (remaining, warnBefore int) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		if rec.Header().Get("Cache-Control") != "no-store" {
+			t.Error("session status may be cached")
+		}
+		var body struct {
+			RemainingSeconds  int `json:"remainingSeconds"`
+			WarnBeforeSeconds int `json:"warnBeforeSeconds"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.RemainingSeconds, body.WarnBeforeSeconds
+	}
+
+	rec := httptest.NewRecorder()
+	h.Status(rec, httptest.NewRequest(http.MethodGet, "/api/admin/session", nil))
+	remaining, warnBefore := decode(rec)
+	if remaining <= 0 || remaining > 180 || warnBefore != 300 {
+		t.Errorf("remaining %ds, warnBefore %ds; want under 180 and 300", remaining, warnBefore)
+	}
+	if sessions.touched != 0 {
+		t.Error("Status extended the session")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/session/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: appmw.SessionCookieName, Value: "signed"})
+	rec = httptest.NewRecorder()
+	h.Refresh(rec, req)
+	if remaining, _ := decode(rec); remaining < 3500 || sessions.touched != 1 {
+		t.Errorf("after refresh: remaining %ds, touched %d", remaining, sessions.touched)
+	}
+	c := rec.Result().Cookies()
+	if len(c) != 1 || c[0].Value != "signed" || !c[0].Secure || c[0].Expires.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("session cookie not extended: %v", c)
+	}
+
+	sessions.err = store.ErrNotFound
+	rec = httptest.NewRecorder()
+	h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/api/admin/session/refresh", nil))
+	assertJSONError(t, rec, http.StatusUnauthorized)
+}
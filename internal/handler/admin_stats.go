@@ -68,10 +68,11 @@ type StatsHandler struct {
 	events    statsDataSource
 	schemas   statsSchemaLoader
 	delivery  deliveryStatsSource
+	activity  *ActivityCounter
 }
 
-func NewStatsHandler(logger *slog.Logger, events statsDataSource, schemas statsSchemaLoader, delivery deliveryStatsSource, tmpl *template.Template) *StatsHandler {
-	return &StatsHandler{BaseHandler: BaseHandler{logger: logger}, templates: tmpl, events: events, schemas: schemas, delivery: delivery}
+func NewStatsHandler(logger *slog.Logger, events statsDataSource, schemas statsSchemaLoader, delivery deliveryStatsSource, activity *ActivityCounter, tmpl *template.Template) *StatsHandler {
+	return &StatsHandler{BaseHandler: BaseHandler{logger: logger}, templates: tmpl, events: events, schemas: schemas, delivery: delivery, activity: activity}
 }
 
 // Page renders the admin stats page with real data.
@@ -80,21 +81,21 @@ func (h *StatsHandler) Page(w http.ResponseWriter, r *http.Request) {
 
 	stats, err := h.events.Stats(ctx)
 	if err != nil {
-		slog.Error("stats: failed to load stats", "err", err)
+		slog.ErrorContext(r.Context(), "stats: failed to load stats", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	schema, err := h.schemas.LiveSchema(ctx)
 	if err != nil {
-		slog.Error("stats: failed to load schema", "err", err)
+		slog.ErrorContext(r.Context(), "stats: failed to load schema", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	delivery, err := h.delivery.Stats24h(ctx)
 	if err != nil {
-		slog.Error("stats: failed to load delivery stats", "err", err)
+		slog.ErrorContext(r.Context(), "stats: failed to load delivery stats", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -120,7 +121,7 @@ func (h *StatsHandler) Page(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, "admin_stats.html", data); err != nil {
-		slog.Error("stats: template error", "err", err)
+		slog.ErrorContext(r.Context(), "stats: template error", "err", err)
 	}
 }
 
@@ -163,11 +164,7 @@ func buildFieldStats(counts []store.FieldCount, total int64, schema *model.Repor
 	}
 	nameByID := make(map[string]string, len(schema.Fields))
 	for _, f := range schema.Fields {
-		label := f.Locale(schema.DefaultLang()).Label
-		if label == "" {
-			label = f.ID
-		}
-		nameByID[f.ID] = label
+		nameByID[f.ID] = f.Locale(schema.DefaultLang()).Label
 	}
 
 	stats := make([]FieldStat, 0, len(counts))
@@ -2,14 +2,18 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/firewatch/internal/auth"
 	appmw "github.com/firewatch/internal/middleware"
 	"github.com/firewatch/internal/mailer"
 	"github.com/firewatch/internal/model"
+	"github.com/firewatch/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -33,6 +37,7 @@ type adminUsersPageData struct {
 
 // UsersHandler handles super-admin user management.
 type UsersHandler struct {
+	BaseHandler
 	users         userManagementStore
 	sessions      allSessionDeleter
 	mailer        mailer.InviteSender
@@ -40,15 +45,15 @@ type UsersHandler struct {
 	templates     *template.Template
 }
 
-func NewUsersHandler(users userManagementStore, sessions allSessionDeleter, m mailer.InviteSender, inviteBaseURL string, tmpl *template.Template) *UsersHandler {
-	return &UsersHandler{users: users, sessions: sessions, mailer: m, inviteBaseURL: inviteBaseURL, templates: tmpl}
+func NewUsersHandler(logger *slog.Logger, users userManagementStore, sessions allSessionDeleter, m mailer.InviteSender, inviteBaseURL string, tmpl *template.Template) *UsersHandler {
+	return &UsersHandler{BaseHandler: BaseHandler{logger: logger}, users: users, sessions: sessions, mailer: m, inviteBaseURL: inviteBaseURL, templates: tmpl}
 }
 
 // Page renders the user management page.
 func (h *UsersHandler) Page(w http.ResponseWriter, r *http.Request) {
 	users, err := h.users.ListAll(r.Context())
 	if err != nil {
-		slog.Error("users: failed to list", "err", err)
+		slog.ErrorContext(r.Context(), "users: failed to list", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -58,45 +63,44 @@ func (h *UsersHandler) Page(w http.ResponseWriter, r *http.Request) {
 		Nonce:        appmw.NonceFromContext(r.Context()),
 	}
 	if err := h.templates.ExecuteTemplate(w, "admin_users.html", data); err != nil {
-		slog.Error("users: template error", "err", err)
+		slog.ErrorContext(r.Context(), "users: template error", "err", err)
 	}
 }
 
 // List returns all admin users as JSON.
 func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
 	// TODO: implement
-	w.WriteHeader(http.StatusNotImplemented)
+	h.errorResponse(w, r, http.StatusNotImplemented, "not implemented")
 }
 
 // Invite sends an invitation to a new admin user.
 func (h *UsersHandler) Invite(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		h.errorResponse(w, r, http.StatusBadRequest, "the request body could not be parsed")
 		return
 	}
 	email := r.FormValue("email")
 	role := r.FormValue("role")
 	if email == "" || role == "" {
-		http.Error(w, "email and role are required", http.StatusBadRequest)
+		h.errorResponse(w, r, http.StatusBadRequest, "email and role are required")
 		return
 	}
 	if role != string(model.RoleAdmin) && role != string(model.RoleSuperAdmin) {
-		http.Error(w, "invalid role", http.StatusBadRequest)
+		h.errorResponse(w, r, http.StatusBadRequest, "invalid role")
 		return
 	}
 
 	token := auth.GenerateToken()
 	id := auth.NewID()
 	if err := h.users.CreateInvite(r.Context(), id, email, role, token); err != nil {
-		slog.Error("invite: failed to create invite", "err", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.serverErrorResponse(w, r, fmt.Errorf("invite: create invite: %w", err))
 		return
 	}
 
 	if h.inviteBaseURL != "" && h.mailer != nil {
-		inviteURL := h.inviteBaseURL + "/accept-invite?token=" + token
+		inviteURL := inviteLink(r.Context(), h.inviteBaseURL) + "?token=" + token
 		if err := h.mailer.SendInvite(email, inviteURL); err != nil {
-			slog.Error("invite: failed to send invite email", "email", email, "err", err)
+			slog.ErrorContext(r.Context(), "invite: failed to send invite email", "email", email, "err", err)
 		}
 	}
 
@@ -105,29 +109,92 @@ func (h *UsersHandler) Invite(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("Invitation sent."))
 }
 
-// Update changes a user's role or status.
+// Update changes a user's role or status. Setting the status to inactive is
+// the default way to remove an admin: the row is kept so audit history still
+// resolves, but the account can no longer log in.
 func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement
-	_ = chi.URLParam(r, "id")
-	_ = appmw.UserIDFromContext(r.Context())
-	w.WriteHeader(http.StatusNotImplemented)
+	id := chi.URLParam(r, "id")
+	callerID := appmw.UserIDFromContext(r.Context())
+
+	if err := r.ParseForm(); err != nil {
+		h.errorResponse(w, r, http.StatusBadRequest, "the request body could not be parsed")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		h.errorResponse(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.serverErrorResponse(w, r, fmt.Errorf("users: load user: %w", err))
+		return
+	}
+
+	role := user.Role
+	if v := r.FormValue("role"); v != "" {
+		role = model.Role(v)
+	}
+	status := user.Status
+	if v := r.FormValue("status"); v != "" {
+		status = model.Status(v)
+	}
+	if role != model.RoleAdmin && role != model.RoleSuperAdmin {
+		h.errorResponse(w, r, http.StatusBadRequest, "invalid role")
+		return
+	}
+	if status != model.StatusActive && status != model.StatusInactive {
+		h.errorResponse(w, r, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if id == callerID && (role != user.Role || status != user.Status) {
+		h.errorResponse(w, r, http.StatusBadRequest, "Cannot change your own role or status")
+		return
+	}
+
+	if err := h.users.UpdateRoleAndStatus(r.Context(), id, role, status); err != nil {
+		if errors.Is(err, store.ErrLastSuperAdmin) {
+			h.errorResponse(w, r, http.StatusConflict, "Cannot demote or deactivate the last super admin")
+			return
+		}
+		h.serverErrorResponse(w, r, fmt.Errorf("users: update: %w", err))
+		return
+	}
+	if status == model.StatusInactive {
+		_ = h.sessions.DeleteAllByUserID(r.Context(), id)
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
-// Delete removes a user account.
+// Delete permanently removes a user account. Prefer deactivating via Update;
+// this is kept for cases where the record itself must go.
 func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	callerID := appmw.UserIDFromContext(r.Context())
 
 	if id == callerID {
-		http.Error(w, "Cannot delete your own account", http.StatusBadRequest)
+		h.errorResponse(w, r, http.StatusBadRequest, "Cannot delete your own account")
 		return
 	}
 
 	if err := h.users.Delete(r.Context(), id); err != nil {
-		slog.Error("users: failed to delete", "err", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, store.ErrLastSuperAdmin) {
+			h.errorResponse(w, r, http.StatusConflict, "Cannot delete the last super admin")
+			return
+		}
+		h.serverErrorResponse(w, r, fmt.Errorf("users: delete: %w", err))
 		return
 	}
 	_ = h.sessions.DeleteAllByUserID(r.Context(), id)
 	w.WriteHeader(http.StatusOK)
 }
+
+// inviteLink returns the accept-invite URL under baseURL and the app's base
+// path. A baseURL that already ends in the base path is accepted as well.
+func inviteLink(ctx context.Context, baseURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if prefix := appmw.URL(ctx, ""); prefix != "" {
+		baseURL = strings.TrimSuffix(baseURL, prefix)
+	}
+	return baseURL + appmw.URL(ctx, "/accept-invite")
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/firewatch/internal/auth"
+	appmw "github.com/firewatch/internal/middleware"
+	"github.com/firewatch/internal/store"
+)
+
+// panicWipeConfirmation must be typed into the confirm field of a panic wipe.
+const panicWipeConfirmation = "WIPE"
+
+type panicWiper interface {
+	PanicWipe(ctx context.Context, userID string, maintenance bool) (store.WipeResult, error)
+}
+
+type passwordHashReader interface {
+	GetPasswordHashByID(ctx context.Context, id string) (string, error)
+}
+
+var (
+	_ panicWiper         = (*store.SettingsStore)(nil)
+	_ passwordHashReader = (*store.UserStore)(nil)
+)
+
+// PanicWipeHandler handles the super-admin panic wipe.
+type PanicWipeHandler struct {
+	BaseHandler
+	wiper         panicWiper
+	users         passwordHashReader
+	secureCookies bool
+	sameSite      http.SameSite
+}
+
+func NewPanicWipeHandler(logger *slog.Logger, wiper panicWiper, users passwordHashReader, secureCookies bool, sameSite http.SameSite) *PanicWipeHandler {
+	return &PanicWipeHandler{BaseHandler: BaseHandler{logger: logger}, wiper: wiper, users: users, secureCookies: secureCookies, sameSite: sameSite}
+}
+
+type panicWipeRequest struct {
+	Password    string `json:"password"`
+	Confirm     string `json:"confirm"`
+	Maintenance bool   `json:"maintenance"`
+}
+
+// Wipe signs every admin out, the caller included, invalidates all pending
+// invites and, if asked, turns maintenance mode on. It is meant for an admin
+// under duress, so it needs the caller's password and the confirmation word
+// but nothing else. The action is written to the audit log.
+func (h *PanicWipeHandler) Wipe(w http.ResponseWriter, r *http.Request) {
+	var req panicWipeRequest
+	if err := h.readJSON(w, r, &req); err != nil {
+		h.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Confirm != panicWipeConfirmation {
+		h.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("type %s to confirm", panicWipeConfirmation))
+		return
+	}
+
+	userID := appmw.UserIDFromContext(r.Context())
+	hash, err := h.users.GetPasswordHashByID(r.Context(), userID)
+	if err != nil || !auth.Verify(hash, req.Password) {
+		h.errorResponse(w, r, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	res, err := h.wiper.PanicWipe(r.Context(), userID, req.Maintenance)
+	if err != nil {
+		h.serverErrorResponse(w, r, fmt.Errorf("panic wipe: %w", err))
+		return
+	}
+	h.logger.WarnContext(r.Context(), "admin: panic wipe",
+		"user_id", userID,
+		"sessions", res.Sessions,
+		"invites", res.Invites,
+		"maintenance", res.Maintenance,
+	)
+
+	// The caller's own session is gone too; drop the cookie with it.
+	http.SetCookie(w, &http.Cookie{
+		Name:     appmw.SessionCookieName,
+		Value:    "",
+		Path:     appmw.CookiePath(r.Context()),
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: h.sameSite,
+	})
+	if err := h.writeJSON(w, http.StatusOK, envelope{"wiped": res}, nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
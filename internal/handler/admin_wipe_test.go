@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appmw "github.com/firewatch/internal/middleware"
+	"github.com/firewatch/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type recordingWiper struct {
+	calls       int
+	maintenance bool
+}
+
+func (f *recordingWiper) PanicWipe(ctx context.Context, userID string, maintenance bool) (store.WipeResult, error) {
+	f.calls++
+	f.maintenance = maintenance
+	return store.WipeResult{Sessions: 2, Invites: 1, Maintenance: maintenance}, nil
+}
+
+func TestPanicWipe(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(wiper *recordingWiper, body string) *httptest.ResponseRecorder {
+		h := NewPanicWipeHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), wiper, &fakeLoginUsers{hash: string(hash)}, false, http.SameSiteStrictMode)
+		rec := httptest.NewRecorder()
+		h.Wipe(rec, httptest.NewRequest(http.MethodPost, "/api/admin/panic-wipe", strings.NewReader(body)))
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name, body string
+		wantStatus int
+	}{
+		{"not confirmed", `{"password":"correct-horse-battery","confirm":"wipe","maintenance":true}`, http.StatusBadRequest},
+		{"wrong password", `{"password":"wrong","confirm":"WIPE","maintenance":true}`, http.StatusForbidden},
+		{"malformed", `{"password":`, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			wiper := &recordingWiper{}
+			rec := post(wiper, tc.body)
+			assertJSONError(t, rec, tc.wantStatus)
+			if wiper.calls != 0 {
+				t.Error("rejected request still wiped")
+			}
+		})
+	}
+
+	wiper := &recordingWiper{}
+	rec := post(wiper, `{"password":"correct-horse-battery","confirm":"WIPE","maintenance":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if wiper.calls != 1 || !wiper.maintenance {
+		t.Errorf("wiper calls = %d, maintenance = %v", wiper.calls, wiper.maintenance)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"wiped":{"sessions":2,"invites":1,"maintenance":true}}` {
+		t.Errorf("body = %s", got)
+	}
+	if c := rec.Result().Cookies(); len(c) != 1 || c[0].Name != appmw.SessionCookieName || c[0].MaxAge >= 0 {
+		t.Errorf("session cookie not cleared: %v", c)
+	}
+}
@@ -19,9 +19,12 @@ func (h *BaseHandler) logError(r *http.Request, err error) {
 	method := r.Method
 	uri := r.URL.RequestURI()
 
-	h.logger.Error(err.Error(), "method", method, "uri", uri)
+	h.logger.ErrorContext(r.Context(), err.Error(), "method", method, "uri", uri)
 }
 
+// errorResponse writes message as a JSON {"error": ...} body with status.
+// writeJSON only fails before anything is written, so falling back to a bare
+// 500 never writes a second status.
 func (h *BaseHandler) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 	env := envelope{"error": message}
 
@@ -40,7 +43,17 @@ func (h *BaseHandler) serverErrorResponse(w http.ResponseWriter, r *http.Request
 }
 
 
+// writeJSON encodes data before writing anything, so an error return means
+// the response is untouched and the caller can still send an error response.
+// Once the status is written, a failed write (usually a client that went
+// away) is not reported, since nothing more can be sent.
 func (h *BaseHandler) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
 	for k, v := range headers {
 		for _, value := range v {
 			w.Header().Add(k, value)
@@ -49,11 +62,7 @@ func (h *BaseHandler) writeJSON(w http.ResponseWriter, status int, data any, hea
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	encoder := json.NewEncoder(w)
-
-	if err := encoder.Encode(data); err != nil {
-		return err
-	}
+	_, _ = w.Write(js)
 
 	return nil
 }
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestConstructorsSetLogger guards against a handler constructor leaving
+// BaseHandler.logger unset, which would make logError panic on the first
+// server error.
+func TestConstructorsSetLogger(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for name, base := range map[string]BaseHandler{
+		"NewReportHandler":      NewReportHandler(logger, nil, nil, nil, nil, nil, nil, nil, 0, nil, 0, nil).BaseHandler,
+		"NewSettingsHandler":    NewSettingsHandler(logger, nil, nil, nil, nil).BaseHandler,
+		"NewAdminReportHandler": NewAdminReportHandler(logger, nil, nil).BaseHandler,
+		"NewStatsHandler":       NewStatsHandler(logger, nil, nil, nil, nil, nil).BaseHandler,
+		"NewUsersHandler":       NewUsersHandler(logger, nil, nil, nil, "", nil).BaseHandler,
+	} {
+		if base.logger != logger {
+			t.Errorf("%s does not set BaseHandler.logger", name)
+		}
+	}
+}
+
+// assertJSONError checks that rr holds status and exactly one JSON object
+// with a non-empty "error" field, so a second write would be caught.
+func assertJSONError(t *testing.T, rr *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rr.Code != status {
+		t.Errorf("status = %d, want %d", rr.Code, status)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	dec := json.NewDecoder(rr.Body)
+	var body map[string]any
+	if err := dec.Decode(&body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if msg, _ := body["error"].(string); msg == "" {
+		t.Errorf("body %v has no error message", body)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		t.Errorf("body has trailing data after the error object")
+	}
+}
+
+func TestServerErrorAfterFailedWriteJSON(t *testing.T) {
+	h := &BaseHandler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// A channel can't be marshalled, so writeJSON must fail without having
+	// written a status or partial body.
+	if err := h.writeJSON(rr, http.StatusOK, envelope{"ch": make(chan int)}, nil); err == nil {
+		t.Fatal("writeJSON succeeded for an unmarshalable value")
+	}
+	h.serverErrorResponse(rr, r, errors.New("boom"))
+
+	assertJSONError(t, rr, http.StatusInternalServerError)
+}
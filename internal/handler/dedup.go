@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// duplicateWindow is how long an identical report is treated as a repeat.
+const duplicateWindow = 2 * time.Minute
+
+// idempotencyWindow is how long a client-supplied Idempotency-Key is
+// remembered, and maxIdempotencyKeyLen bounds its size.
+const (
+	idempotencyWindow    = 15 * time.Minute
+	maxIdempotencyKeyLen = 255
+)
+
+// Idempotency-Key outcomes. Only these markers are stored, never the report.
+// An accepted marker is followed by the language the response was given in,
+// so a replay answers the same way.
+const (
+	idempotencyInFlight = "in-flight"
+	idempotencyAccepted = "accepted:"
+)
+
+// ttlCache is a small in-memory map whose entries expire after ttl. Expired
+// entries are pruned on every write, so nothing outlives the window for long.
+type ttlCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]ttlEntry
+	now     func() time.Time
+}
+
+type ttlEntry struct {
+	value   string
+	expires time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, entries: make(map[string]ttlEntry), now: time.Now}
+}
+
+func (c *ttlCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (c *ttlCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *ttlCache) set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, value)
+}
+
+// reserve sets key to value unless it already holds a live entry, in which
+// case it returns that entry's value and false. The check and the write
+// happen under one lock, so of two concurrent callers only one gets true.
+func (c *ttlCache) reserve(key, value string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && c.now().Before(e.expires) {
+		return e.value, false
+	}
+	c.setLocked(key, value)
+	return "", true
+}
+
+// setLocked prunes expired entries and stores key. The caller holds mu.
+func (c *ttlCache) setLocked(key, value string) {
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// newFingerprintSalt returns the per-process key used to hash submissions, so
+// fingerprints can't be matched against anything outside this process.
+func newFingerprintSalt() []byte {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// submissionFingerprint hashes the normalised field values of a report. Only
+// the digest is kept; the content itself is never stored.
+func submissionFingerprint(salt []byte, fields map[string]string) string {
+	ids := make([]string, 0, len(fields))
+	for id := range fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	mac := hmac.New(sha256.New, salt)
+	for _, id := range ids {
+		v := strings.Join(strings.Fields(fields[id]), " ")
+		if v == "" {
+			continue
+		}
+		mac.Write([]byte(id))
+		mac.Write([]byte{0})
+		mac.Write([]byte(v))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// statusRecorder remembers the status code written through it and, for an
+// accepted submission, the language accepted answered in.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	lang   string
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
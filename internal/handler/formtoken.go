@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Form tokens are "<unix seconds>.<hex HMAC-SHA256>", signed when a form is
+// rendered. The handler trusts the render time only if the signature
+// matches, so the anti-bot timing check can't be bypassed by making up a
+// timestamp. They are stateless: nothing is stored per form.
+
+// The domains separate each form's MACs from each other and from anything
+// else signed with the same secret, so a report form token can't be replayed
+// on the invite form.
+const (
+	formTokenDomain       = "report-form:"
+	inviteFormTokenDomain = "invite-form:"
+)
+
+func signFormToken(key []byte, issued time.Time) string {
+	return signTimedToken(key, formTokenDomain, issued)
+}
+
+// formTokenAge returns how long ago token was issued, or false if the token
+// is malformed or its signature doesn't verify under any of keys.
+func formTokenAge(keys [][]byte, token string, now time.Time) (time.Duration, bool) {
+	return timedTokenAge(keys, formTokenDomain, token, now)
+}
+
+func signTimedToken(key []byte, domain string, issued time.Time) string {
+	ts := strconv.FormatInt(issued.Unix(), 10)
+	return ts + "." + formTokenMAC(key, domain, ts)
+}
+
+// timedTokenAge accepts a token signed with any of keys, so forms rendered
+// just before a secret rotation can still be submitted.
+func timedTokenAge(keys [][]byte, domain, token string, now time.Time) (time.Duration, bool) {
+	ts, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, false
+	}
+	if !slices.ContainsFunc(keys, func(key []byte) bool {
+		return hmac.Equal([]byte(sig), []byte(formTokenMAC(key, domain, ts)))
+	}) {
+		return 0, false
+	}
+	issued, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(time.Unix(issued, 0)), true
+}
+
+func formTokenMAC(key []byte, domain, ts string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(domain + ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -4,14 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+
+	"github.com/firewatch/internal/mailer"
 )
 
 type pinger interface {
 	PingContext(ctx context.Context) error
 }
 
-// Health returns a health check handler that verifies database connectivity.
-func Health(db pinger) http.HandlerFunc {
+type breakerStater interface {
+	BreakerState() mailer.BreakerState
+}
+
+// Health returns a health check handler that verifies database connectivity
+// and reports the mail queue's circuit breaker state. An open breaker is
+// reported but doesn't fail the check, since the app itself is still serving.
+func Health(db pinger, mail breakerStater) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		status := "ok"
 		code := http.StatusOK
@@ -23,6 +31,9 @@ func Health(db pinger) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(code)
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": status})
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"status": status,
+			"mail":   string(mail.BreakerState()),
+		})
 	}
 }
@@ -3,12 +3,19 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/firewatch/internal/mailer"
 	"github.com/firewatch/internal/middleware"
@@ -21,93 +28,113 @@ type reportEventRecorder interface {
 
 type schemaLoader interface {
 	LiveSchema(ctx context.Context) (*model.ReportSchema, error)
+	LiveWithRevision(ctx context.Context) (*model.ReportSchema, int64, error)
+	LiveStamp(ctx context.Context) (revision int64, publishedAt time.Time, err error)
 }
 
+// schemaCacheControl lets clients reuse the public schema briefly; after that
+// they revalidate with If-None-Match, which is answered without a body.
+const schemaCacheControl = "public, max-age=60"
+
 type deliveryRecorder interface {
 	Record(ctx context.Context, kind, status string)
 }
 
+type reportSettingsStore interface {
+	Load(ctx context.Context) (*model.AppSettings, error)
+	Save(ctx context.Context, settings *model.AppSettings) error
+}
+
+// errNoReportSender is reported when the handler was built without a mailer.
+// The submission is still accepted and counted as a failed delivery.
+var errNoReportSender = errors.New("no report sender configured")
+
 // ReportHandler handles the public report form and submission.
 type ReportHandler struct {
 	BaseHandler
 	schemas   schemaLoader
+	settings  reportSettingsStore
 	sessions  middleware.SessionReader
 	mailer    mailer.ReportSender
 	events    reportEventRecorder
 	delivery  deliveryRecorder
 	templates *template.Template
+	maxBody   int64
+	formKeys  [][]byte // sign (first) and verify the form's anti-bot timing token
+	logLevel  slog.Level
+	activity  *ActivityCounter
+
+	// recent holds fingerprints of reports sent in the last duplicateWindow;
+	// idempotency maps hashed Idempotency-Key headers to their outcome.
+	recent      *ttlCache
+	idempotency *ttlCache
+	salt        []byte
 }
 
 type reportFormData struct {
 	Page          model.PageLocale
+	SchemaVersion int64 // the live revision, echoed back as _v
 	Fields        []reportFieldView
 	Languages     []model.LangInfo
 	CurrentLang   string
 	IsAdmin       bool
-	FormTimestamp int64
+	FormToken     string
+	Submitted     bool // redirected here after a form post without JavaScript
 	Nonce         string
 }
 
+// reportFieldView is a field with its strings resolved for one language. It
+// backs both the rendered form and the single-language JSON view.
 type reportFieldView struct {
-	ID          string
-	Type        string
-	Required    bool
-	Prefix      string
-	Options     []string
-	Label       string
-	Description string
-	Placeholder string
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	MaxLength   int      `json:"maxLength"`
+	Prefix      string   `json:"prefix,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Label       string   `json:"label"`
+	Description string   `json:"description"`
+	Placeholder string   `json:"placeholder"`
 }
 
-func NewReportHandler(logger *slog.Logger, schemas schemaLoader, sessions middleware.SessionReader, m mailer.ReportSender, events reportEventRecorder, delivery deliveryRecorder, tmpl *template.Template) *ReportHandler {
-	return &ReportHandler{BaseHandler: BaseHandler{logger: logger}, schemas: schemas, sessions: sessions, mailer: m, events: events, delivery: delivery, templates: tmpl}
+// localizedSchema is the schema flattened to a single language.
+type localizedSchema struct {
+	SchemaVersion int64             `json:"schemaVersion"`
+	Lang          string            `json:"lang"`
+	Languages     []string          `json:"languages"`
+	Page          model.PageLocale  `json:"page"`
+	Fields        []reportFieldView `json:"fields"`
+}
+
+func NewReportHandler(logger *slog.Logger, schemas schemaLoader, settings reportSettingsStore, sessions middleware.SessionReader, m mailer.ReportSender, events reportEventRecorder, delivery deliveryRecorder, tmpl *template.Template, maxBody int64, formKeys [][]byte, logLevel slog.Level, activity *ActivityCounter) *ReportHandler {
+	return &ReportHandler{BaseHandler: BaseHandler{logger: logger}, schemas: schemas, settings: settings, sessions: sessions, mailer: m, events: events, delivery: delivery, templates: tmpl, maxBody: maxBody, formKeys: formKeys, logLevel: logLevel, activity: activity, recent: newTTLCache(duplicateWindow), idempotency: newTTLCache(idempotencyWindow), salt: newFingerprintSalt()}
+}
+
+// FormToken issues a fresh form token for front-ends that render the form
+// themselves, such as a mirror on another origin. The token goes in the
+// submission's "_t" field like the one embedded in the server-rendered form.
+func (h *ReportHandler) FormToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if err := h.writeJSON(w, http.StatusOK, envelope{"formToken": signFormToken(h.formKeys[0], time.Now())}, nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
 }
 
 // Form renders the public report form.
 func (h *ReportHandler) Form(w http.ResponseWriter, r *http.Request) {
-	schema, err := h.schemas.LiveSchema(r.Context())
+	schema, revision, err := h.schemas.LiveWithRevision(r.Context())
 	if err != nil {
 		slog.Error("report: failed to load live schema", "err", err)
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Resolve language from query param, falling back to schema default.
-	lang := r.URL.Query().Get("lang")
-	if !containsString(schema.Languages, lang) {
-		lang = schema.DefaultLang()
-	}
-
-	// Sort fields by per-language display order.
-	fields := make([]model.Field, len(schema.Fields))
-	copy(fields, schema.Fields)
-	sort.Slice(fields, func(i, j int) bool {
-		return fields[i].DisplayOrder(lang) < fields[j].DisplayOrder(lang)
-	})
-
-	// Build flat field views with resolved locale strings.
-	fieldViews := make([]reportFieldView, len(fields))
-	for i, f := range fields {
-		locale := f.Locale(lang)
-		prefix := locale.Prefix
-		if prefix == "" {
-			prefix = f.Prefix
-		}
-		fieldViews[i] = reportFieldView{
-			ID:          f.ID,
-			Type:        f.Type,
-			Required:    f.Required,
-			Prefix:      prefix,
-			Options:     f.Options,
-			Label:       locale.Label,
-			Description: locale.Description,
-			Placeholder: locale.Placeholder,
-		}
-	}
+	lang := schema.ResolveLang(r.URL.Query().Get("lang"))
+	fieldViews := localizeFields(schema, lang)
 
-	// Resolve enabled languages with names from SupportedLanguages.
+	// Resolve enabled languages with names from the built-in and custom ones.
 	enabledLangs := make([]model.LangInfo, 0, len(schema.Languages))
-	for _, info := range model.SupportedLanguages {
+	for _, info := range schema.AvailableLanguages() {
 		if containsString(schema.Languages, info.Code) {
 			enabledLangs = append(enabledLangs, info)
 		}
@@ -122,94 +149,262 @@ func (h *ReportHandler) Form(w http.ResponseWriter, r *http.Request) {
 
 	data := reportFormData{
 		Page:          schema.Page.Locale(lang),
+		SchemaVersion: revision,
 		Fields:        fieldViews,
 		Languages:     enabledLangs,
 		CurrentLang:   lang,
 		IsAdmin:       isAdmin,
-		FormTimestamp: time.Now().Unix(),
+		FormToken:     signFormToken(h.formKeys[0], time.Now()),
+		Submitted:     r.URL.Query().Get("submitted") == "1",
 		Nonce:         middleware.NonceFromContext(r.Context()),
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, "report_form.html", data); err != nil {
 		slog.Error("report: template error", "err", err)
 	}
 }
 
+// Get returns the live schema. With ?lang=xx it returns only that language,
+// flattened the way Form renders it, instead of every locale. The ETag is
+// derived from the live schema's revision, so it changes on every publish.
+// Clients echo schemaVersion back on submit, so it carries the revision
+// rather than the stored layout version.
 func (h *ReportHandler) Get(w http.ResponseWriter, r *http.Request) {
-	schema, err := h.schemas.LiveSchema(r.Context())
+	schema, revision, err := h.schemas.LiveWithRevision(r.Context())
 	if err != nil {
 		h.logger.Error("report: failed to load live schema", "err", err)
 		h.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = h.writeJSON(w, http.StatusOK, envelope{"schema": schema}, nil)
+	etag := fmt.Sprintf(`"%d"`, revision)
+	if r.URL.Query().Has("lang") {
+		etag = fmt.Sprintf(`"%d-%s"`, revision, schema.ResolveLang(r.URL.Query().Get("lang")))
+	}
+	w.Header().Set("Cache-Control", schemaCacheControl)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	published := *schema
+	published.SchemaVersion = int(revision)
+	var body any = &published
+	if r.URL.Query().Has("lang") {
+		lang := schema.ResolveLang(r.URL.Query().Get("lang"))
+		body = localizedSchema{
+			SchemaVersion: revision,
+			Lang:          lang,
+			Languages:     schema.Languages,
+			Page:          schema.Page.Locale(lang),
+			Fields:        localizeFields(schema, lang),
+		}
+	}
+
+	err = h.writeJSON(w, http.StatusOK, envelope{"schema": body}, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// SchemaVersion returns just enough of the live schema for a client to tell
+// whether its cached copy is stale. revision changes on every publish or
+// rollback; schemaVersion is the same value, kept for clients that read it
+// to echo back on submit.
+func (h *ReportHandler) SchemaVersion(w http.ResponseWriter, r *http.Request) {
+	revision, publishedAt, err := h.schemas.LiveStamp(r.Context())
 	if err != nil {
 		h.serverErrorResponse(w, r, err)
 		return
 	}
+
+	etag := fmt.Sprintf(`"%d"`, revision)
+	w.Header().Set("Cache-Control", schemaCacheControl)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, envelope{
+		"schemaVersion": revision,
+		"revision":      revision,
+		"updatedAt":     publishedAt,
+	}, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
 }
 
 func (h *ReportHandler) RedirectToLogin(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/admin/login", http.StatusFound)
+	http.Redirect(w, r, middleware.URL(r.Context(), "/admin/login"), http.StatusFound)
 }
 
 // Submit processes an anonymous report submission.
 func (h *ReportHandler) Submit(w http.ResponseWriter, r *http.Request) {
-	schema, err := h.schemas.LiveSchema(r.Context())
-	if err != nil {
-		w.WriteHeader(http.StatusAccepted)
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		h.submit(w, r)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		h.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must not be longer than %d characters", maxIdempotencyKeyLen))
+		return
+	}
+
+	k := submissionFingerprint(h.salt, map[string]string{"idempotency-key": key})
+	if outcome, reserved := h.idempotency.reserve(k, idempotencyInFlight); !reserved {
+		if lang, ok := strings.CutPrefix(outcome, idempotencyAccepted); ok {
+			h.accepted(w, r, lang)
+			return
+		}
+		h.errorResponse(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
 		return
 	}
 
-	var req struct {
-		SchemaVersion int               `json:"schemaVersion"`
-		Fields        map[string]string `json:"fields"`
-		Honeypot      string            `json:"_hp"`
-		Timestamp     int64             `json:"_t"`
+	rec := &statusRecorder{ResponseWriter: w}
+	h.submit(rec, r)
+	if rec.status == http.StatusAccepted || rec.status == http.StatusSeeOther {
+		h.idempotency.set(k, idempotencyAccepted+rec.lang)
+	} else {
+		// Let the client fix the request and retry with the same key.
+		h.idempotency.delete(k)
+	}
+}
+
+func (h *ReportHandler) submit(w http.ResponseWriter, r *http.Request) {
+	schema, revision, err := h.schemas.LiveWithRevision(r.Context())
+	if err != nil {
+		h.accepted(w, r, "")
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+	req, err := decodeSubmission(r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			h.errorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Report too large (max %s)", formatBytes(maxBytesError.Limit)))
+			return
+		}
+		h.errorResponse(w, r, http.StatusBadRequest, "the report could not be read")
 		return
 	}
 
 	// Honeypot: real users never see this field; bots fill it in.
 	if req.Honeypot != "" {
-		w.WriteHeader(http.StatusAccepted) // silent drop
+		h.accepted(w, r, req.Lang) // silent drop
 		return
 	}
 
-	// Timing: reject submissions that arrive too fast (bot) or with a stale
-	// token (replayed request). Silently drop both to avoid leaking the mechanism.
-	age := time.Now().Unix() - req.Timestamp
-	if age < 3 || age > 6*3600 {
-		w.WriteHeader(http.StatusAccepted) // silent drop
+	// Timing: reject submissions with a forged token, that arrive too fast
+	// (bot) or with a stale token (replayed request). Silently drop all of
+	// them to avoid leaking the mechanism.
+	age, ok := formTokenAge(h.formKeys, req.FormToken, time.Now())
+	if !ok || age < 3*time.Second || age > 6*time.Hour {
+		h.accepted(w, r, req.Lang) // silent drop
 		return
 	}
 
+	// A form rendered before the last publish or rollback may carry fields
+	// that no longer exist or miss ones that were added. Ask the client to
+	// reload rather than forwarding a report that doesn't match the live form.
+	if req.SchemaVersion != revision {
+		h.errorResponse(w, r, http.StatusConflict, "the report form has changed, please reload the page and try again")
+		return
+	}
+	for id := range req.Fields {
+		if !schema.HasField(id) {
+			h.errorResponse(w, r, http.StatusConflict, "the report form has changed, please reload the page and try again")
+			return
+		}
+	}
+
+	// Bound each value so a single field can't carry megabytes into the
+	// encrypted email. The schema decides whether to truncate or reject.
+	for _, f := range schema.Fields {
+		v, ok := req.Fields[f.ID]
+		if !ok || utf8.RuneCountInString(v) <= f.MaxLen() {
+			continue
+		}
+		if !schema.TruncateOverlong {
+			h.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("field %q must not be longer than %d characters", f.ID, f.MaxLen()))
+			return
+		}
+		req.Fields[f.ID] = truncateRunes(v, f.MaxLen())
+	}
+
 	// Validate required fields.
 	for _, f := range schema.Fields {
 		if f.Required {
 			if v := req.Fields[f.ID]; v == "" {
-				http.Error(w, "Bad Request", http.StatusBadRequest)
+				h.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("field %q is required", f.ID))
 				return
 			}
 		}
 	}
 
-	// Always use the English email template for admin notifications.
+	// Strip tracking parameters from pasted links unless the deployment has
+	// opted into verbatim text. Scrub by default if settings can't be read.
+	s, err := h.settings.Load(r.Context())
+	if err != nil || !s.KeepTrackingParams {
+		for id, v := range req.Fields {
+			req.Fields[id] = scrubTrackingParams(v)
+		}
+	}
+
+	// A double-click or client retry resends the same report. Answer as if it
+	// were accepted again, but don't forward a second copy.
+	var fp string
+	if err != nil || !s.ForwardDuplicateReports {
+		fp = submissionFingerprint(h.salt, req.Fields)
+		if _, reserved := h.recent.reserve(fp, ""); !reserved {
+			h.accepted(w, r, req.Lang)
+			return
+		}
+	}
+
+	// Always use the English email template for admin notifications. The
+	// received time is left out unless the admin opted in, and if settings
+	// can't be read.
+	lang := schema.ResolveLang(req.Lang)
+	var received time.Time
+	if err == nil && s.IncludeReceivedTime {
+		received = time.Now()
+	}
 	emailTmpl := schema.EmailTemplates[model.LangEN]
-	body := mailer.RenderTemplate(emailTmpl, req.Fields)
-	if err := h.mailer.SendReport(body); err != nil {
+	body := reportHeader(revision, lang, received) + mailer.RenderTemplate(emailTmpl, req.Fields)
+	sendErr := errNoReportSender
+	if h.mailer != nil {
+		sendErr = h.mailer.SendReport(body)
+	}
+	if sendErr != nil {
+		// Forget the report, so the reporter's retry is sent rather than
+		// dropped as a duplicate of one that never went out.
+		if fp != "" {
+			h.recent.delete(fp)
+		}
 		// Log but do not surface to submitter.
-		slog.Error("report: smtp send failed", "err", err)
+		slog.Error("report: smtp send failed", "err", sendErr, "transient", errors.Is(sendErr, mailer.ErrTransient))
 		h.delivery.Record(r.Context(), "submission", "error")
+		if errors.Is(sendErr, mailer.ErrPGPNotConfigured) {
+			h.disableForPGP(r.Context(), sendErr)
+		}
 	} else {
 		h.delivery.Record(r.Context(), "submission", "ok")
 	}
 
-	// Record which fields were filled (no values, just IDs) for aggregate stats.
+	// Record which fields were filled (no values, just IDs) for aggregate
+	// stats. Sensitive fields are left out of the stats and the counts below.
 	filledIDs := make([]string, 0, len(req.Fields))
+	countedFields := 0
 	for _, f := range schema.Fields {
+		if f.Sensitive {
+			continue
+		}
+		countedFields++
 		if req.Fields[f.ID] != "" {
 			filledIDs = append(filledIDs, f.ID)
 		}
@@ -218,11 +413,194 @@ func (h *ReportHandler) Submit(w http.ResponseWriter, r *http.Request) {
 		slog.Error("report: failed to record event", "err", err)
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	_, _ = w.Write([]byte(`{"status":"submitted"}`))
+	// One line per submission for operators. Only counts and settings go in
+	// here: never field values, and never anything about the submitter.
+	h.logger.Log(r.Context(), h.logLevel, "report submitted",
+		"schema_version", revision,
+		"fields_filled", len(filledIDs),
+		"fields_total", countedFields,
+		"lang", lang,
+		"encrypted", sendErr == nil || errors.Is(sendErr, mailer.ErrNotSent),
+		"queued", sendErr == nil,
+	)
+	h.activity.Record(lang)
+
+	h.accepted(w, r, lang)
+}
+
+// reportHeader is the metadata block put above a forwarded report so
+// recipients can triage it. It says nothing about the submitter: the live
+// revision the form was filled in from and its language, and the time
+// received, to the minute in UTC, unless received is zero.
+func reportHeader(revision int64, lang string, received time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Schema version: %d\nLanguage: %s\n", revision, lang)
+	if !received.IsZero() {
+		fmt.Fprintf(&b, "Received: %s\n", received.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// submission is the body of POST /api/report.
+type submission struct {
+	SchemaVersion int64             `json:"schemaVersion"` // the live revision the form was rendered from
+	Fields        map[string]string `json:"fields"`
+	Honeypot      string            `json:"_hp"`
+	FormToken     string            `json:"_t"`
+	Lang          string            `json:"lang"`
+}
+
+// decodeSubmission reads a JSON body, or a form-encoded one from the public
+// form posted without JavaScript, where fields arrive as fields[<id>].
+func decodeSubmission(r *http.Request) (submission, error) {
+	var req submission
+	if !isFormPost(r) {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&req)
+		return req, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+	req.Fields = make(map[string]string)
+	for key, values := range r.PostForm {
+		id, ok := strings.CutPrefix(key, "fields[")
+		if id, ok = strings.CutSuffix(id, "]"); ok && id != "" {
+			req.Fields[id] = values[0]
+		}
+	}
+	req.SchemaVersion, _ = strconv.ParseInt(r.PostForm.Get("_v"), 10, 64)
+	req.Honeypot = r.PostForm.Get("_hp")
+	req.FormToken = r.PostForm.Get("_t")
+	req.Lang = r.PostForm.Get("lang")
+	return req, nil
+}
+
+func isFormPost(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded"
+}
+
+// accepted answers a submission that was taken (or silently dropped, which
+// must look the same). API clients get 202 {"status":"submitted"}, plus the
+// form's success message and safety info in lang when it sets them; a browser
+// form post that didn't ask for JSON is redirected to the form in lang, which
+// shows them, so reloading the page doesn't post again.
+func (h *ReportHandler) accepted(w http.ResponseWriter, r *http.Request, lang string) {
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.lang = lang
+	}
+	if isFormPost(r) && !acceptsJSON(r) {
+		target := "/?submitted=1"
+		if lang != "" {
+			target += "&lang=" + url.QueryEscape(lang)
+		}
+		http.Redirect(w, r, middleware.URL(r.Context(), target), http.StatusSeeOther)
+		return
+	}
+	env := envelope{"status": "submitted"}
+	if schema, err := h.schemas.LiveSchema(r.Context()); err == nil {
+		page := schema.Page.Locale(schema.ResolveLang(lang))
+		if page.SuccessMessage != "" {
+			env["message"] = page.SuccessMessage
+		}
+		if page.SafetyInfo != "" {
+			env["safetyInfo"] = page.SafetyInfo
+		}
+	}
+	if err := h.writeJSON(w, http.StatusAccepted, env, nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptsJSON reports whether the Accept header lists application/json.
+func acceptsJSON(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// disableForPGP records a failed PGP verification so the public form goes
+// into maintenance until an admin fixes the key. Transient failures never
+// get here: they don't mean the configuration is wrong.
+func (h *ReportHandler) disableForPGP(ctx context.Context, cause error) {
+	s, err := h.settings.Load(ctx)
+	if err != nil {
+		slog.Error("report: failed to load settings to disable form", "err", err)
+		return
+	}
+	s.SetPGPResult(cause.Error(), time.Now())
+	if err := h.settings.Save(ctx, s); err != nil {
+		slog.Error("report: failed to disable form after PGP error", "err", err)
+		return
+	}
+	slog.Warn("report: PGP key missing — public form disabled", "err", cause)
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// localizeFields resolves each field's strings for lang and sorts the fields
+// by that language's display order.
+func localizeFields(schema *model.ReportSchema, lang string) []reportFieldView {
+	fields := make([]model.Field, len(schema.Fields))
+	copy(fields, schema.Fields)
+	// Stable, so fields sharing an order keep their schema order.
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].DisplayOrder(lang) < fields[j].DisplayOrder(lang)
+	})
+
+	views := make([]reportFieldView, len(fields))
+	for i, f := range fields {
+		locale := f.Locale(lang)
+		prefix := locale.Prefix
+		if prefix == "" {
+			prefix = f.Prefix
+		}
+		views[i] = reportFieldView{
+			ID:          f.ID,
+			Type:        f.Type,
+			Required:    f.Required,
+			MaxLength:   f.MaxLen(),
+			Prefix:      prefix,
+			Options:     f.Options,
+			Label:       locale.Label,
+			Description: locale.Description,
+			Placeholder: locale.Placeholder,
+		}
+	}
+	return views
 }
 
 // containsString reports whether s is in the slice.
 func containsString(slice []string, s string) bool {
 	return slices.Contains(slice, s)
 }
+
+// formatBytes renders n for people, e.g. "1 MB" or "512 KB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<20)), ".0") + " MB"
+	case n >= 1<<10:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<10)), ".0") + " KB"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
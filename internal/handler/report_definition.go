@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/firewatch/internal/model"
+)
+
+// formDefinitionVersion is the formatVersion of GET /api/report/definition.
+// Bump it only for changes that break existing consumers: removing or
+// renaming a key, or changing its meaning. Adding keys is not one of them.
+const formDefinitionVersion = 1
+
+// formDefinition is the live form in one language for third-party form
+// builders. It is a documented contract (see docs/design.md), deliberately
+// separate from model.ReportSchema so the stored layout can change without
+// breaking consumers.
+type formDefinition struct {
+	FormatVersion int                      `json:"formatVersion"`
+	Revision      int64                    `json:"revision"`
+	SchemaVersion int64                    `json:"schemaVersion"`
+	Lang          string                   `json:"lang"`
+	Languages     []formDefinitionLanguage `json:"languages"`
+	Page          formDefinitionPage       `json:"page"`
+	Fields        []formDefinitionField    `json:"fields"`
+}
+
+type formDefinitionLanguage struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type formDefinitionPage struct {
+	Title          string `json:"title"`
+	Subtitle       string `json:"subtitle"`
+	SubmitLabel    string `json:"submitLabel"`
+	SuccessMessage string `json:"successMessage,omitempty"`
+	SafetyInfo     string `json:"safetyInfo,omitempty"`
+}
+
+type formDefinitionField struct {
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	Sensitive   bool     `json:"sensitive"`
+	MaxLength   int      `json:"maxLength"`
+	Options     []string `json:"options"`
+	Label       string   `json:"label"`
+	Description string   `json:"description"`
+	Placeholder string   `json:"placeholder"`
+	Prefix      string   `json:"prefix"`
+}
+
+// Definition returns the live form as a formDefinition in the language asked
+// for with ?lang=xx, or the default language. Caching works as for Get.
+func (h *ReportHandler) Definition(w http.ResponseWriter, r *http.Request) {
+	schema, revision, err := h.schemas.LiveWithRevision(r.Context())
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+	lang := schema.ResolveLang(r.URL.Query().Get("lang"))
+
+	etag := fmt.Sprintf(`"def%d-%d-%s"`, formDefinitionVersion, revision, lang)
+	w.Header().Set("Cache-Control", schemaCacheControl)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := h.writeJSON(w, http.StatusOK, buildFormDefinition(schema, revision, lang), nil); err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+func buildFormDefinition(schema *model.ReportSchema, revision int64, lang string) formDefinition {
+	def := formDefinition{
+		FormatVersion: formDefinitionVersion,
+		Revision:      revision,
+		SchemaVersion: revision,
+		Lang:          lang,
+		Languages:     []formDefinitionLanguage{},
+		Fields:        []formDefinitionField{},
+	}
+	for _, l := range schema.AvailableLanguages() {
+		if containsString(schema.Languages, l.Code) {
+			def.Languages = append(def.Languages, formDefinitionLanguage{Code: l.Code, Name: l.Name})
+		}
+	}
+
+	page := schema.Page.Locale(lang)
+	def.Page = formDefinitionPage{
+		Title:          page.Title,
+		Subtitle:       page.Subtitle,
+		SubmitLabel:    page.SubmitButtonLabel,
+		SuccessMessage: page.SuccessMessage,
+		SafetyInfo:     page.SafetyInfo,
+	}
+
+	sensitive := make(map[string]bool)
+	for _, f := range schema.Fields {
+		sensitive[f.ID] = f.Sensitive
+	}
+	for _, f := range localizeFields(schema, lang) {
+		options := f.Options
+		if options == nil {
+			options = []string{}
+		}
+		def.Fields = append(def.Fields, formDefinitionField{
+			ID:          f.ID,
+			Type:        f.Type,
+			Required:    f.Required,
+			Sensitive:   sensitive[f.ID],
+			MaxLength:   f.MaxLength,
+			Options:     options,
+			Label:       f.Label,
+			Description: f.Description,
+			Placeholder: f.Placeholder,
+			Prefix:      f.Prefix,
+		})
+	}
+	return def
+}
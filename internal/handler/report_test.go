@@ -0,0 +1,904 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firewatch/internal/mailer"
+	"github.com/firewatch/internal/model"
+	"github.com/firewatch/internal/web"
+)
+
+type staticSchema struct{ s model.ReportSchema }
+
+// staticRevision is the live revision staticSchema reports; submissions must
+// echo it as schemaVersion.
+const staticRevision int64 = 1
+
+func (f staticSchema) LiveSchema(ctx context.Context) (*model.ReportSchema, error) {
+	s := f.s
+	return &s, nil
+}
+
+func (f staticSchema) LiveWithRevision(ctx context.Context) (*model.ReportSchema, int64, error) {
+	s := f.s
+	return &s, staticRevision, nil
+}
+
+func (staticSchema) LiveStamp(ctx context.Context) (int64, time.Time, error) {
+	return staticRevision, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), nil
+}
+
+type staticSettings struct{ s model.AppSettings }
+
+func (f staticSettings) Load(ctx context.Context) (*model.AppSettings, error) {
+	s := f.s
+	return &s, nil
+}
+
+func (staticSettings) Save(ctx context.Context, s *model.AppSettings) error { return nil }
+
+// savedSettings keeps whatever was last saved.
+type savedSettings struct {
+	mu sync.Mutex
+	s  model.AppSettings
+}
+
+func (f *savedSettings) Load(ctx context.Context) (*model.AppSettings, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.s
+	return &s, nil
+}
+
+func (f *savedSettings) Save(ctx context.Context, s *model.AppSettings) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.s = *s
+	return nil
+}
+
+type countingSender struct {
+	mu   sync.Mutex
+	sent []string
+	err  error // returned instead of sending when set
+}
+
+func (c *countingSender) SendReport(body string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, body)
+	return nil
+}
+
+func (c *countingSender) CanEncrypt() error { return nil }
+
+func (c *countingSender) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sent)
+}
+
+var testFormKey = []byte("0123456789abcdef0123456789abcdef")
+
+type nopEvents struct{}
+
+func (nopEvents) RecordEvent(ctx context.Context, ids []string) error { return nil }
+
+type nopDelivery struct{}
+
+func (nopDelivery) Record(ctx context.Context, kind, status string) {}
+
+type recordingDelivery struct {
+	mu     sync.Mutex
+	status []string
+}
+
+func (d *recordingDelivery) Record(ctx context.Context, kind, status string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = append(d.status, status)
+}
+
+func (d *recordingDelivery) statuses() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.status...)
+}
+
+func newTestReportHandler(settings model.AppSettings, sender *countingSender) *ReportHandler {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	schema := staticSchema{model.DefaultSALUTESchema()}
+	return NewReportHandler(logger, schema, staticSettings{settings}, nil, sender, nopEvents{}, nopDelivery{}, nil, 1<<20, [][]byte{testFormKey}, slog.LevelInfo, NewActivityCounter())
+}
+
+func submitReport(h *ReportHandler, activity string) *httptest.ResponseRecorder {
+	return submitReportWithKey(h, activity, "")
+}
+
+func submitReportWithKey(h *ReportHandler, activity, key string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"schemaVersion":%d,"_t":%q,"fields":{"size":"2","activity":%q,"location":"park","time":"noon"}}`,
+		staticRevision, signFormToken(testFormKey, time.Now().Add(-10*time.Second)), activity)
+	req := httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.Submit(rec, req)
+	return rec
+}
+
+func TestSubmitDropsDuplicateReports(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	for i := 0; i < 2; i++ {
+		if rec := submitReport(h, "walking"); rec.Code != http.StatusAccepted {
+			t.Fatalf("submit %d: status %d: %s", i, rec.Code, rec.Body)
+		}
+	}
+	// Whitespace differences still count as the same report.
+	submitReport(h, "  walking ")
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports, want 1", n)
+	}
+
+	submitReport(h, "running")
+	if n := sender.count(); n != 2 {
+		t.Errorf("sent %d reports after a different report, want 2", n)
+	}
+}
+
+func TestSubmitRetriesAfterFailedSendAreNotDuplicates(t *testing.T) {
+	sender := &countingSender{err: errors.New("421 service not available")}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	submitReport(h, "walking")
+	sender.mu.Lock()
+	sender.err = nil
+	sender.mu.Unlock()
+	submitReport(h, "walking")
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports, want the retry after a failed send to go out", n)
+	}
+}
+
+func TestSubmitDuplicatesAllowedWhenDisabled(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{ForwardDuplicateReports: true}, sender)
+
+	submitReport(h, "walking")
+	submitReport(h, "walking")
+	if n := sender.count(); n != 2 {
+		t.Errorf("sent %d reports, want 2", n)
+	}
+}
+
+func TestSubmitRejectsFormFromEarlierRevision(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	// The layout version is the same before and after a publish, so only
+	// the revision tells a stale form apart.
+	body := fmt.Sprintf(`{"schemaVersion":%d,"_t":%q,"fields":{"size":"2","activity":"walking","location":"park","time":"noon"}}`,
+		staticRevision-1, signFormToken(testFormKey, time.Now().Add(-10*time.Second)))
+	rec := httptest.NewRecorder()
+	h.Submit(rec, httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body)))
+	assertJSONError(t, rec, http.StatusConflict)
+	if n := sender.count(); n != 0 {
+		t.Errorf("sent %d reports from a stale form, want 0", n)
+	}
+}
+
+func TestSubmitAddsReportHeader(t *testing.T) {
+	// The live revision, not the layout version, which is the same for
+	// every form and tells the recipient nothing.
+	version := staticRevision
+	for _, tc := range []struct {
+		includeTime bool
+		want        string
+	}{
+		{false, fmt.Sprintf("Schema version: %d\nLanguage: en\n\n", version)},
+		{true, fmt.Sprintf("Schema version: %d\nLanguage: en\nReceived: ", version)},
+	} {
+		sender := &countingSender{}
+		h := newTestReportHandler(model.AppSettings{IncludeReceivedTime: tc.includeTime}, sender)
+		if rec := submitReport(h, "walking"); rec.Code != http.StatusAccepted {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		body := sender.sent[0]
+		if !strings.HasPrefix(body, tc.want) {
+			t.Errorf("includeReceivedTime=%v: body starts %q, want %q", tc.includeTime, body, tc.want)
+		}
+		if !tc.includeTime && strings.Contains(body, "Received") {
+			t.Errorf("received time sent without opting in:\n%s", body)
+		}
+	}
+}
+
+func TestReportHeaderTruncatesToMinuteUTC(t *testing.T) {
+	received := time.Date(2026, 3, 4, 10, 20, 59, 0, time.FixedZone("EST", -5*60*60))
+	got := reportHeader(4, "es", received)
+	want := "Schema version: 4\nLanguage: es\nReceived: 2026-03-04 15:20 UTC\n\n"
+	if got != want {
+		t.Errorf("reportHeader = %q, want %q", got, want)
+	}
+}
+
+func TestTTLCacheExpires(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newTTLCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("k", "v")
+	if v, ok := c.get("k"); !ok || v != "v" {
+		t.Fatalf("get = %q, %v", v, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.get("k"); ok {
+		t.Error("entry should have expired")
+	}
+	c.set("other", "")
+	if len(c.entries) != 1 {
+		t.Errorf("expired entries not pruned: %d left", len(c.entries))
+	}
+}
+
+func TestTTLCacheReserve(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newTTLCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.reserve("k", "first"); !ok {
+		t.Fatal("reserve on an empty cache failed")
+	}
+	if v, ok := c.reserve("k", "second"); ok || v != "first" {
+		t.Errorf("reserve = %q, %v; want the existing entry and false", v, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.reserve("k", "third"); !ok {
+		t.Error("reserve over an expired entry failed")
+	}
+}
+
+// gatedSender blocks every send until release is closed.
+type gatedSender struct {
+	countingSender
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedSender) SendReport(body string) error {
+	g.started <- struct{}{}
+	<-g.release
+	return g.countingSender.SendReport(body)
+}
+
+func TestSubmitDropsConcurrentDuplicates(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	// A double-click sends both copies at once; only one may go out.
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if rec := submitReport(h, "walking"); rec.Code != http.StatusAccepted {
+				t.Errorf("status %d: %s", rec.Code, rec.Body)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports, want 1", n)
+	}
+}
+
+func TestSubmitIdempotencyKeyConcurrentRetry(t *testing.T) {
+	sender := &gatedSender{started: make(chan struct{}, 2), release: make(chan struct{})}
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.mailer = sender
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- submitReportWithKey(h, "walking", "key-1") }()
+	<-sender.started
+
+	// The first request is mid-send; a retry with the same key must not
+	// send a second copy.
+	if rec := submitReportWithKey(h, "walking", "key-1"); rec.Code != http.StatusConflict {
+		t.Errorf("concurrent retry status %d, want 409", rec.Code)
+	}
+	close(sender.release)
+	if rec := <-done; rec.Code != http.StatusAccepted {
+		t.Errorf("first request status %d, want 202", rec.Code)
+	}
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports, want 1", n)
+	}
+}
+
+func TestSubmitIdempotencyKey(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{ForwardDuplicateReports: true}, sender)
+
+	// Different content under the same key: the retry must not send again.
+	for _, activity := range []string{"walking", "walking slowly"} {
+		rec := submitReportWithKey(h, activity, "key-1")
+		if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), "submitted") {
+			t.Fatalf("status %d body %q", rec.Code, rec.Body)
+		}
+	}
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports with one key, want 1", n)
+	}
+
+	submitReportWithKey(h, "walking", "key-2")
+	if n := sender.count(); n != 2 {
+		t.Errorf("sent %d reports after a new key, want 2", n)
+	}
+}
+
+func TestSubmitIdempotencyKeyReplaysLanguage(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	post := func() *httptest.ResponseRecorder {
+		body := url.Values{
+			"_v":               {fmt.Sprint(staticRevision)},
+			"_t":               {signFormToken(testFormKey, time.Now().Add(-10*time.Second))},
+			"lang":             {"en"},
+			"fields[size]":     {"2"},
+			"fields[activity]": {"walking"},
+			"fields[location]": {"park"},
+			"fields[time]":     {"noon"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		h.Submit(rec, req)
+		return rec
+	}
+
+	first, replay := post(), post()
+	if first.Code != http.StatusSeeOther || replay.Code != first.Code {
+		t.Fatalf("status %d then %d, want 303 both times", first.Code, replay.Code)
+	}
+	if got, want := replay.Header().Get("Location"), first.Header().Get("Location"); got != want {
+		t.Errorf("replay redirected to %q, want the original %q", got, want)
+	}
+}
+
+func TestSubmitIdempotencyKeyForgetsFailures(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	// Missing required field: 400, and the key stays usable.
+	if rec := submitReportWithKey(h, "", "key-1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	if rec := submitReportWithKey(h, "walking", "key-1"); rec.Code != http.StatusAccepted {
+		t.Fatalf("retry status %d, want 202", rec.Code)
+	}
+	if n := sender.count(); n != 1 {
+		t.Errorf("sent %d reports, want 1", n)
+	}
+}
+
+func TestSubmitSeparatesTooLargeFromMalformed(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.maxBody = 1536
+
+	rec := httptest.NewRecorder()
+	body := `{"fields":{"activity":"` + strings.Repeat("x", 2048) + `"}}`
+	h.Submit(rec, httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), "max 1.5 KB") {
+		t.Errorf("413 body %q does not state the limit", rec.Body)
+	}
+	assertJSONError(t, rec, http.StatusRequestEntityTooLarge)
+
+	rec = httptest.NewRecorder()
+	h.Submit(rec, httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(`{"fields":`)))
+	assertJSONError(t, rec, http.StatusBadRequest)
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		100:             "100 bytes",
+		512 << 10:       "512 KB",
+		1 << 20:         "1 MB",
+		5<<20 + 512<<10: "5.5 MB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSubmitNegotiatesResponse(t *testing.T) {
+	form := func() url.Values {
+		return url.Values{
+			"_v":               {fmt.Sprint(staticRevision)},
+			"_t":               {signFormToken(testFormKey, time.Now().Add(-10*time.Second))},
+			"lang":             {"es"},
+			"fields[size]":     {"2"},
+			"fields[activity]": {"walking"},
+			"fields[location]": {"park"},
+			"fields[time]":     {"noon"},
+		}
+	}
+	post := func(h *ReportHandler, body url.Values, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.Submit(rec, req)
+		return rec
+	}
+
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	// A browser form post is redirected to the localized thank-you page; "es"
+	// isn't enabled on the default schema so it resolves to English.
+	rec := post(h, form(), "text/html,application/xhtml+xml")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/?submitted=1&lang=en" {
+		t.Fatalf("form post: status %d location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if sender.count() != 1 || !strings.Contains(sender.sent[0], "walking") {
+		t.Fatal("form post did not send the report")
+	}
+
+	// A silent drop looks exactly the same.
+	bot := form()
+	bot.Set("_hp", "http://spam.example")
+	if rec := post(h, bot, ""); rec.Code != http.StatusSeeOther {
+		t.Errorf("honeypot form post: status %d, want 303", rec.Code)
+	}
+
+	// Asking for JSON gets the JSON contract regardless of body encoding.
+	other := form()
+	other.Set("fields[activity]", "running")
+	rec = post(h, other, "application/json")
+	if rec.Code != http.StatusAccepted || strings.TrimSpace(rec.Body.String()) != `{"status":"submitted"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("JSON accept: status %d type %q body %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if rec := submitReport(h, "cycling"); rec.Code != http.StatusAccepted {
+		t.Errorf("JSON body: status %d, want 202", rec.Code)
+	}
+}
+
+func TestSubmitReturnsSuccessMessage(t *testing.T) {
+	schema := model.DefaultSALUTESchema()
+	schema.Languages = []string{model.LangEN, model.LangES}
+	es := schema.Page.I18n[model.LangES]
+	es.SuccessMessage = "Gracias."
+	es.SafetyInfo = "Línea de ayuda: 555-0100"
+	schema.Page.I18n[model.LangES] = es
+
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.schemas = staticSchema{schema}
+
+	post := func(lang, activity string) map[string]string {
+		body := fmt.Sprintf(`{"schemaVersion":%d,"lang":%q,"_t":%q,"fields":{"size":"2","activity":%q,"location":"park","time":"noon"}}`,
+			staticRevision, lang, signFormToken(testFormKey, time.Now().Add(-10*time.Second)), activity)
+		rec := httptest.NewRecorder()
+		h.Submit(rec, httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status %d, want 202: %s", rec.Code, rec.Body)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return got
+	}
+
+	got := post(model.LangES, "walking")
+	if got["status"] != "submitted" || got["message"] != "Gracias." || got["safetyInfo"] != "Línea de ayuda: 555-0100" {
+		t.Errorf("es response = %v", got)
+	}
+
+	// Unset in English: the response stays as it always was.
+	got = post(model.LangEN, "running")
+	if len(got) != 1 || got["status"] != "submitted" {
+		t.Errorf("en response = %v, want only status", got)
+	}
+}
+
+func TestFormTokenEndpoint(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	rec := httptest.NewRecorder()
+	h.FormToken(rec, httptest.NewRequest(http.MethodGet, "/api/report/form-token", nil))
+
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+	}
+	var resp struct {
+		FormToken string `json:"formToken"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := formTokenAge([][]byte{testFormKey}, resp.FormToken, time.Now()); !ok {
+		t.Errorf("issued token %q does not verify", resp.FormToken)
+	}
+}
+
+func TestFormToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tok := signFormToken(testFormKey, now.Add(-time.Minute))
+
+	if age, ok := formTokenAge([][]byte{testFormKey}, tok, now); !ok || age != time.Minute {
+		t.Errorf("formTokenAge = %s, %v; want 1m, true", age, ok)
+	}
+
+	ts, _, _ := strings.Cut(tok, ".")
+	forged := fmt.Sprintf("%d%s", now.Add(-time.Hour).Unix(), tok[len(ts):])
+	for _, bad := range []string{"", "123", forged, tok + "0"} {
+		if _, ok := formTokenAge([][]byte{testFormKey}, bad, now); ok {
+			t.Errorf("formTokenAge accepted %q", bad)
+		}
+	}
+	other := []byte("another key, 32 bytes long.....!")
+	if _, ok := formTokenAge([][]byte{other}, tok, now); ok {
+		t.Error("token verified under a different key")
+	}
+	// After a rotation the old key stays in the list, so a form rendered
+	// before the restart can still be submitted.
+	if _, ok := formTokenAge([][]byte{other, testFormKey}, tok, now); !ok {
+		t.Error("token signed with the previous key was rejected")
+	}
+}
+
+func TestSubmitDropsForgedFormToken(t *testing.T) {
+	sender := &countingSender{}
+	h := newTestReportHandler(model.AppSettings{}, sender)
+
+	body := fmt.Sprintf(`{"schemaVersion":%d,"_t":"%d.deadbeef","fields":{"size":"2","activity":"x","location":"park","time":"noon"}}`,
+		staticRevision, time.Now().Unix()-10)
+	rec := httptest.NewRecorder()
+	h.Submit(rec, httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body)))
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status %d, want a silent 202", rec.Code)
+	}
+	if n := sender.count(); n != 0 {
+		t.Errorf("forged token sent %d reports", n)
+	}
+}
+
+func TestSubmitLogsNoFieldContents(t *testing.T) {
+	var buf strings.Builder
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	if rec := submitReport(h, "secret-activity-text"); rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", rec.Code)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "report submitted") || !strings.Contains(out, "fields_filled=4") {
+		t.Errorf("missing submission log line: %s", out)
+	}
+	for _, v := range []string{"secret-activity-text", "park", "noon"} {
+		if strings.Contains(out, v) {
+			t.Errorf("log contains field value %q: %s", v, out)
+		}
+	}
+}
+
+func TestSubmitLogSeparatesEncryptionFromDelivery(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{nil, "encrypted=true queued=true"},
+		{fmt.Errorf("%w: %w: 421 try later", mailer.ErrNotSent, mailer.ErrTransient), "encrypted=true queued=false"},
+		{mailer.ErrPGPNotConfigured, "encrypted=false queued=false"},
+		{fmt.Errorf("%w: bad key", mailer.ErrEncrypt), "encrypted=false queued=false"},
+		{errors.New("render report template: bad template"), "encrypted=false queued=false"},
+	} {
+		var buf strings.Builder
+		h := newTestReportHandler(model.AppSettings{}, &countingSender{err: tc.err})
+		h.logger = slog.New(slog.NewTextHandler(&buf, nil))
+		h.settings = &memSettingsStore{s: &model.AppSettings{}}
+
+		submitReport(h, "walking")
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("send error %v: log line lacks %q: %s", tc.err, tc.want, buf.String())
+		}
+		if want := fmt.Sprintf("schema_version=%d ", staticRevision); !strings.Contains(buf.String(), want) {
+			t.Errorf("log line lacks the live revision %q: %s", want, buf.String())
+		}
+	}
+}
+
+type recordingEvents struct{ ids []string }
+
+func (e *recordingEvents) RecordEvent(ctx context.Context, ids []string) error {
+	e.ids = append(e.ids, ids...)
+	return nil
+}
+
+func TestSubmitLeavesSensitiveFieldsOutOfLogsAndStats(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	schema := model.DefaultSALUTESchema()
+	for i := range schema.Fields {
+		if schema.Fields[i].ID == "activity" {
+			schema.Fields[i].Sensitive = true
+		}
+	}
+	events := &recordingEvents{}
+	sender := &countingSender{}
+	h := NewReportHandler(logger, staticSchema{schema}, staticSettings{}, nil, sender, events, nopDelivery{}, nil, 1<<20, [][]byte{testFormKey}, slog.LevelInfo, NewActivityCounter())
+
+	if rec := submitReport(h, "walking"); rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", rec.Code)
+	}
+	if slices.Contains(events.ids, "activity") {
+		t.Errorf("sensitive field recorded in stats: %v", events.ids)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "fields_filled=3") || !strings.Contains(out, fmt.Sprintf("fields_total=%d", len(schema.Fields)-1)) {
+		t.Errorf("sensitive field counted in log line: %s", out)
+	}
+	if sender.count() != 1 || !strings.Contains(sender.sent[0], "walking") {
+		t.Error("sensitive field value was not sent in the report")
+	}
+}
+
+func TestSubmitLogLevel(t *testing.T) {
+	var buf strings.Builder
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	h.logLevel = slog.LevelInfo
+
+	submitReport(h, "patrol")
+	if strings.Contains(buf.String(), "report submitted") {
+		t.Errorf("info-level submission line logged with a warn threshold: %s", buf.String())
+	}
+}
+
+func TestSubmitWithoutMailer(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, nil)
+	h.mailer = nil
+	delivery := &recordingDelivery{}
+	h.delivery = delivery
+
+	if rec := submitReport(h, "patrol"); rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", rec.Code)
+	}
+	if got := delivery.statuses(); len(got) != 1 || got[0] != "error" {
+		t.Errorf("delivery statuses = %v, want [error]", got)
+	}
+}
+
+func TestSubmitDisablesFormWhenPGPNotConfigured(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		err          error
+		wantVerified bool
+	}{
+		{"permanent", mailer.ErrPGPNotConfigured, false},
+		{"transient", fmt.Errorf("%w: dial: connection refused", mailer.ErrTransient), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := &savedSettings{s: model.AppSettings{SMTPVerified: true, PGPVerified: true}}
+			h := newTestReportHandler(model.AppSettings{}, &countingSender{err: tc.err})
+			h.settings = settings
+
+			if rec := submitReport(h, "patrol"); rec.Code != http.StatusAccepted {
+				t.Fatalf("status %d, want 202", rec.Code)
+			}
+			if settings.s.PGPVerified != tc.wantVerified {
+				t.Errorf("PGPVerified = %v, want %v", settings.s.PGPVerified, tc.wantVerified)
+			}
+		})
+	}
+}
+
+func TestFormOffersCustomLanguage(t *testing.T) {
+	schema := model.DefaultSALUTESchema()
+	schema.CustomLanguages = []model.LangInfo{{Code: "vi", Name: "Tiếng Việt"}}
+	schema.Languages = []string{model.LangEN, "vi"}
+	schema.Page.I18n["vi"] = model.PageLocale{Title: "Báo cáo sự cố cộng đồng"}
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.schemas = staticSchema{schema}
+	h.templates = web.Templates
+
+	rec := httptest.NewRecorder()
+	h.Form(rec, httptest.NewRequest(http.MethodGet, "/?lang=vi", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `<html lang="vi">`) || !strings.Contains(body, "Báo cáo sự cố cộng đồng") {
+		t.Errorf("form not rendered in the custom language:\n%s", body)
+	}
+	if !strings.Contains(body, `href="?lang=vi"`) {
+		t.Error("language picker does not offer the custom language")
+	}
+}
+
+func TestLocalizeFieldsKeepsSchemaOrderForTies(t *testing.T) {
+	schema := model.DefaultSALUTESchema()
+	for i := range schema.Fields {
+		schema.Fields[i].Order = 1
+	}
+	for range 20 {
+		fields := localizeFields(&schema, model.LangEN)
+		for i, f := range fields {
+			if f.ID != schema.Fields[i].ID {
+				t.Fatalf("position %d is %q, want %q", i, f.ID, schema.Fields[i].ID)
+			}
+		}
+	}
+}
+
+func TestGetSingleLanguage(t *testing.T) {
+	schema := model.DefaultSALUTESchema()
+	schema.Languages = []string{model.LangEN, model.LangES}
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.schemas = staticSchema{schema}
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/report?lang=es", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "i18n") || strings.Contains(body, "Activity") {
+		t.Errorf("response still carries other locales: %s", body)
+	}
+
+	var resp struct {
+		Schema localizedSchema `json:"schema"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := resp.Schema
+	if got.Lang != model.LangES || got.Page.Title != "Informe de Incidentes Comunitarios" {
+		t.Errorf("page = %+v for lang %q", got.Page, got.Lang)
+	}
+	if len(got.Fields) == 0 || got.Fields[0].Label != "Cantidad" || got.Fields[0].Prefix != "C" {
+		t.Errorf("first field = %+v, want the Spanish size field", got.Fields[0])
+	}
+
+	// Unknown languages fall back to the default.
+	rec = httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/report?lang=xx", nil))
+	if !strings.Contains(rec.Body.String(), `"lang":"en"`) {
+		t.Errorf("unknown lang did not fall back to en: %s", rec.Body.String())
+	}
+}
+
+func TestGetHonorsIfNoneMatch(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/report", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Cache-Control") == "" {
+		t.Fatalf("status %d, ETag %q, Cache-Control %q", rec.Code, etag, rec.Header().Get("Cache-Control"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/report", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	rec = httptest.NewRecorder()
+	h.Get(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match: status %d with %d-byte body, want empty 304", rec.Code, rec.Body.Len())
+	}
+
+	// The single-language view is a different representation.
+	req = httptest.NewRequest(http.MethodGet, "/api/report?lang=en", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.Get(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("?lang=en with the full schema's ETag: status %d, want 200", rec.Code)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+
+	rec := httptest.NewRecorder()
+	h.SchemaVersion(rec, httptest.NewRequest(http.MethodGet, "/api/report/schema-version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"schemaVersion": float64(staticRevision),
+		"revision":      float64(staticRevision),
+		"updatedAt":     "2026-01-02T03:04:05Z",
+	}
+	if fmt.Sprint(body) != fmt.Sprint(want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/report/schema-version", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.SchemaVersion(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match status %d, want 304", rec.Code)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	schema := model.DefaultSALUTESchema()
+	schema.Languages = []string{model.LangEN, model.LangES}
+	schema.Fields[0].Sensitive = true
+	h := newTestReportHandler(model.AppSettings{}, &countingSender{})
+	h.schemas = staticSchema{schema}
+
+	rec := httptest.NewRecorder()
+	h.Definition(rec, httptest.NewRequest(http.MethodGet, "/api/report/definition?lang=es", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "i18n") {
+		t.Errorf("definition leaks the stored layout: %s", rec.Body.String())
+	}
+
+	var def formDefinition
+	if err := json.Unmarshal(rec.Body.Bytes(), &def); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if def.FormatVersion != formDefinitionVersion || def.Lang != model.LangES || def.SchemaVersion != staticRevision {
+		t.Errorf("header = formatVersion %d, lang %q, schemaVersion %d", def.FormatVersion, def.Lang, def.SchemaVersion)
+	}
+	if len(def.Languages) != 2 || def.Languages[1].Code != model.LangES {
+		t.Errorf("languages = %+v, want en and es", def.Languages)
+	}
+	if def.Page.Title != "Informe de Incidentes Comunitarios" {
+		t.Errorf("page title = %q", def.Page.Title)
+	}
+	if len(def.Fields) != len(schema.Fields) {
+		t.Fatalf("%d fields, want %d", len(def.Fields), len(schema.Fields))
+	}
+	first := def.Fields[0]
+	if first.ID != schema.Fields[0].ID || first.Label != "Cantidad" || !first.Sensitive || first.Options == nil {
+		t.Errorf("first field = %+v", first)
+	}
+
+	// Each language is its own representation.
+	req := httptest.NewRequest(http.MethodGet, "/api/report/definition?lang=en", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.Definition(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("?lang=en with the Spanish ETag: status %d, want 200", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/report/definition?lang=en", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.Definition(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("matching If-None-Match: status %d, want 304", rec.Code)
+	}
+}
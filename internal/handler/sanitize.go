@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// urlPattern matches http(s) URLs embedded in free text.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// trackingParams are query parameters stripped from submitted URLs.
+// Any parameter beginning with "utm_" is also removed.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_eid":  true,
+	"igshid":  true,
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasPrefix(key, "utm_") || trackingParams[key]
+}
+
+// scrubTrackingParams removes common tracking query parameters from every URL
+// found in s. The rest of the text, and the order of any remaining parameters,
+// is left untouched.
+func scrubTrackingParams(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, func(match string) string {
+		// Sentence punctuation directly after a link isn't part of it.
+		raw := strings.TrimRight(match, ".,;:!?)")
+		trailing := match[len(raw):]
+
+		u, err := url.Parse(raw)
+		if err != nil || u.RawQuery == "" {
+			return match
+		}
+
+		pairs := strings.Split(u.RawQuery, "&")
+		kept := make([]string, 0, len(pairs))
+		for _, pair := range pairs {
+			key, _, _ := strings.Cut(pair, "=")
+			if k, err := url.QueryUnescape(key); err == nil && isTrackingParam(k) {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		if len(kept) == len(pairs) {
+			return match
+		}
+
+		u.RawQuery = strings.Join(kept, "&")
+		u.ForceQuery = false
+		return u.String() + trailing
+	})
+}
+
+// truncateRunes shortens s to at most n characters without splitting a
+// multi-byte character.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,27 @@
+package handler
+
+import "testing"
+
+func TestScrubTrackingParams(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no links", "seen at the park", "seen at the park"},
+		{"no query", "see https://example.org/a", "see https://example.org/a"},
+		{"only tracking", "https://example.org/a?utm_source=x&fbclid=y", "https://example.org/a"},
+		{"keeps order of the rest", "https://example.org/?b=2&utm_medium=m&a=1", "https://example.org/?b=2&a=1"},
+		{"case-insensitive", "https://example.org/?UTM_Source=x&GCLID=y&id=7", "https://example.org/?id=7"},
+		{"trailing punctuation", "Look: https://example.org/p?gclid=1.", "Look: https://example.org/p."},
+		{"several links", "https://a.example/?igshid=1 and https://b.example/?q=x&msclkid=2", "https://a.example/ and https://b.example/?q=x"},
+		{"untouched when clean", "https://example.org/?q=utm", "https://example.org/?q=utm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scrubTrackingParams(tt.in); got != tt.want {
+				t.Errorf("scrubTrackingParams(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
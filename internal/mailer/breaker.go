@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the queue's SMTP circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed means sends go through normally.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen means the relay is failing and the queue is paused.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen means the cooldown has passed and one send is allowed
+	// through to test whether the relay has recovered.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Circuit breaker defaults used by NewQueue.
+const (
+	breakerThreshold = 5
+	breakerCooldown  = time.Minute
+)
+
+// breaker opens after threshold consecutive failures and stays open for
+// cooldown before letting a single trial send through.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool // a half-open trial send is in flight
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *breaker) stateLocked() BreakerState {
+	if b.failures < b.threshold {
+		return BreakerClosed
+	}
+	if b.now().Sub(b.openedAt) < b.cooldown {
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// State reports the current breaker state.
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked()
+}
+
+// allow reports whether a send may be attempted now. In the half-open state
+// only one caller is let through until it reports success or failure.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateLocked() {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return false
+	}
+}
+
+// release gives back a permit from allow that was not used for a send.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		b.failure()
+	}
+	if !b.allow() || b.State() != BreakerClosed {
+		t.Fatalf("breaker opened before threshold: %s", b.State())
+	}
+
+	b.failure()
+	if b.State() != BreakerOpen || b.allow() {
+		t.Fatalf("breaker should be open after threshold, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("breaker should be half-open after cooldown, got %s", b.State())
+	}
+	if !b.allow() {
+		t.Fatal("half-open breaker should allow one trial")
+	}
+	if b.allow() {
+		t.Fatal("half-open breaker should allow only one trial at a time")
+	}
+
+	// A failed trial re-opens for another cooldown.
+	b.failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed trial should re-open the breaker, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("expected a second trial after cooldown")
+	}
+	b.success()
+	if b.State() != BreakerClosed || !b.allow() {
+		t.Fatalf("successful trial should close the breaker, got %s", b.State())
+	}
+}
@@ -0,0 +1,161 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/firewatch/internal/model"
+)
+
+//go:embed emails/*.txt
+var defaultEmailFiles embed.FS
+
+// DefaultOrgName fills {{.OrgName}} when no organisation name is configured.
+const DefaultOrgName = "Firewatch"
+
+// Email template file names. The defaults live in emails/; a file of the same
+// name in the override directory replaces one of them.
+const (
+	inviteEmail = "invite.txt"
+	reportEmail = "report.txt"
+)
+
+// InviteEmailData is the data invite.txt is rendered with.
+type InviteEmailData struct {
+	OrgName   string
+	InviteURL string
+	Expiry    string // how long the link is valid, e.g. "48 hours"
+}
+
+// ReportEmailData is the data report.txt is rendered with. Body is the report
+// as rendered from the form's email template; the whole result is encrypted.
+type ReportEmailData struct {
+	OrgName string
+	Body    string
+}
+
+// EmailTemplates renders the invite and report emails. Each template is a
+// "Subject: ..." line, a blank line, then the body.
+type EmailTemplates struct {
+	orgName string
+	invite  *template.Template
+	report  *template.Template
+}
+
+// activeEmailTemplates is shared by every Mailer, including the short-lived
+// ones built to verify or preview settings, so they all render the same text.
+var activeEmailTemplates atomic.Pointer[EmailTemplates]
+
+func init() {
+	t, err := LoadEmailTemplates("", DefaultOrgName)
+	if err != nil {
+		panic(err)
+	}
+	activeEmailTemplates.Store(t)
+}
+
+// UseEmailTemplates makes t the templates used for all invite and report
+// emails from now on.
+func UseEmailTemplates(t *EmailTemplates) {
+	activeEmailTemplates.Store(t)
+}
+
+// LoadEmailTemplates parses the built-in email templates, replacing any that
+// have a file of the same name in dir. An empty dir uses only the built-ins,
+// and an empty orgName uses DefaultOrgName. Each template is test-rendered so
+// a mistake is reported at startup rather than on the first send.
+func LoadEmailTemplates(dir, orgName string) (*EmailTemplates, error) {
+	if orgName == "" {
+		orgName = DefaultOrgName
+	}
+	t := &EmailTemplates{orgName: orgName}
+	var err error
+	if t.invite, err = loadEmailTemplate(dir, inviteEmail); err != nil {
+		return nil, err
+	}
+	if t.report, err = loadEmailTemplate(dir, reportEmail); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := t.render(t.invite, InviteEmailData{OrgName: orgName, InviteURL: "https://example.org/accept-invite", Expiry: formatExpiry(model.InviteTTL)}); err != nil {
+		return nil, err
+	}
+	if _, _, err := t.render(t.report, ReportEmailData{OrgName: orgName, Body: "report"}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func loadEmailTemplate(dir, name string) (*template.Template, error) {
+	src, err := fs.ReadFile(defaultEmailFiles, "emails/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		override, err := os.ReadFile(filepath.Join(dir, name))
+		switch {
+		case err == nil:
+			src = override
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("read email template: %w", err)
+		}
+	}
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse email template %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// render executes t and splits the result into subject and body. The single
+// newline that ends the file is not part of the body.
+func (e *EmailTemplates) render(t *template.Template, data any) (subject, body string, err error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("render email template %s: %w", t.Name(), err)
+	}
+	head, body, ok := strings.Cut(buf.String(), "\n\n")
+	subject, hasSubject := strings.CutPrefix(head, "Subject: ")
+	if !ok || !hasSubject || strings.Contains(subject, "\n") {
+		return "", "", fmt.Errorf("email template %s must start with a \"Subject: \" line followed by a blank line", t.Name())
+	}
+	return subject, strings.TrimSuffix(body, "\n"), nil
+}
+
+func (e *EmailTemplates) inviteMessage(to, inviteURL string) (Message, error) {
+	subject, body, err := e.render(e.invite, InviteEmailData{
+		OrgName:   e.orgName,
+		InviteURL: inviteURL,
+		Expiry:    formatExpiry(model.InviteTTL),
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: []string{to}, Subject: subject, Body: body}, nil
+}
+
+func (e *EmailTemplates) reportMessage(to []string, report string) (Message, error) {
+	subject, body, err := e.render(e.report, ReportEmailData{OrgName: e.orgName, Body: report})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, Body: body}, nil
+}
+
+// formatExpiry renders d in whole hours where it can, e.g. "48 hours".
+func formatExpiry(d time.Duration) string {
+	if d%time.Hour == 0 && d > time.Hour {
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return d.String()
+}
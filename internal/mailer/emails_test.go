@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultEmailTemplatesMatchBuiltInText(t *testing.T) {
+	e, err := LoadEmailTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := e.inviteMessage("user@example.org", "https://example.org/accept-invite?token=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBody := "You have been invited to access Firewatch.\n\nAccept your invitation:\nhttps://example.org/accept-invite?token=abc\n\nThis link expires in 48 hours."
+	if msg.Subject != "You've been invited to Firewatch" || msg.Body != wantBody {
+		t.Errorf("invite = %q / %q", msg.Subject, msg.Body)
+	}
+
+	msg, err = e.reportMessage([]string{"dest@example.org"}, "line 1\nline 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Report from Firewatch" || msg.Body != "line 1\nline 2\n" {
+		t.Errorf("report = %q / %q", msg.Subject, msg.Body)
+	}
+}
+
+func TestEmailTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	invite := "Subject: Join {{.OrgName}}\r\n\r\nHello from {{.OrgName}}: {{.InviteURL}} ({{.Expiry}})\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "invite.txt"), []byte(invite), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := LoadEmailTemplates(dir, "Eastside Watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := e.inviteMessage("user@example.org", "https://x/y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Join Eastside Watch" || msg.Body != "Hello from Eastside Watch: https://x/y (48 hours)" {
+		t.Errorf("invite = %q / %q", msg.Subject, msg.Body)
+	}
+
+	// report.txt was not overridden, so the built-in one is used.
+	msg, err = e.reportMessage(nil, "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Report from Eastside Watch" {
+		t.Errorf("report subject = %q", msg.Subject)
+	}
+}
+
+func TestEmailTemplateErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"parse error":     "Subject: x\n\n{{.InviteURL",
+		"unknown field":   "Subject: x\n\n{{.Token}}",
+		"missing subject": "Hello {{.InviteURL}}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "invite.txt"), []byte(src), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadEmailTemplates(dir, "")
+			if err == nil || !strings.Contains(err.Error(), "invite.txt") {
+				t.Errorf("LoadEmailTemplates error = %v, want one naming invite.txt", err)
+			}
+		})
+	}
+}
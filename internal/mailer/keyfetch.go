@@ -0,0 +1,190 @@
+package mailer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/firewatch/internal/model"
+)
+
+// DefaultKeyserver is the HKPS keyserver used for fingerprint and email
+// lookups.
+const DefaultKeyserver = "https://keys.openpgp.org"
+
+// maxKeyBytes caps how much of a fetched key is read. Real public keys are a
+// few KiB; anything near this is a mistake or hostile.
+const maxKeyBytes = 256 << 10
+
+// keyFetchTimeout keeps a lookup inside the server's write timeout.
+const keyFetchTimeout = 8 * time.Second
+
+// ErrKeyNotFound is returned when the keyserver has no key for the query.
+var ErrKeyNotFound = errors.New("no key found for that fingerprint or email")
+
+var fingerprintRE = regexp.MustCompile(`^(0x)?[0-9A-Fa-f]{40}$`)
+
+// KeyFetcher retrieves armored PGP public keys over HTTPS, either from a URL
+// or by HKP lookup on Keyserver.
+type KeyFetcher struct {
+	Client    *http.Client
+	Keyserver string
+}
+
+// NewKeyFetcher returns a KeyFetcher that uses DefaultKeyserver and refuses
+// to follow redirects off HTTPS.
+func NewKeyFetcher() *KeyFetcher {
+	return &KeyFetcher{
+		Client: &http.Client{
+			Timeout:       keyFetchTimeout,
+			CheckRedirect: httpsOnlyRedirect,
+		},
+		Keyserver: DefaultKeyserver,
+	}
+}
+
+func httpsOnlyRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing redirect to %s", req.URL.Scheme)
+	}
+	if len(via) >= 5 {
+		return errors.New("too many redirects")
+	}
+	return nil
+}
+
+// Fetch resolves source to an armored public key. source is an https:// URL,
+// a 40-hex-digit fingerprint, or an email address. The result is checked with
+// CanEncrypt, so a private key or anything unparseable is rejected. Binary
+// keys are returned armored.
+func (f *KeyFetcher) Fetch(ctx context.Context, source string) (string, error) {
+	source = strings.TrimSpace(source)
+	var target string
+	switch {
+	case strings.HasPrefix(source, "https://"):
+		target = source
+	case strings.Contains(source, "://"):
+		return "", errors.New("key URLs must use https://")
+	case fingerprintRE.MatchString(source):
+		target = f.lookupURL("0x" + strings.ToUpper(strings.TrimPrefix(source, "0x")))
+	case strings.Contains(source, "@"):
+		target = f.lookupURL(source)
+	default:
+		return "", errors.New("enter an https:// URL, a 40-character fingerprint, or an email address")
+	}
+
+	key, err := f.get(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	if key, err = NormalizePublicKey(key); err != nil {
+		return "", err
+	}
+	if err := New(&Config{PGPPublicKey: key}).CanEncrypt(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (f *KeyFetcher) lookupURL(search string) string {
+	q := url.Values{"op": {"get"}, "options": {"mr"}, "search": {search}}
+	return strings.TrimRight(f.Keyserver, "/") + "/pks/lookup?" + q.Encode()
+}
+
+func (f *KeyFetcher) get(ctx context.Context, target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", errors.New("key URLs must use https://")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch key: server returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeyBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("fetch key: %w", err)
+	}
+	if len(body) > maxKeyBytes {
+		return "", fmt.Errorf("fetch key: response larger than %d KiB", maxKeyBytes>>10)
+	}
+	return string(body), nil
+}
+
+// zbase32 is the z-base-32 alphabet WKD uses for hashed local parts.
+var zbase32 = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+// wkdURLs returns the advanced and direct Web Key Directory URLs for email,
+// in the order they should be tried.
+func wkdURLs(email string) ([]string, error) {
+	local, domain, ok := strings.Cut(strings.TrimSpace(email), "@")
+	if !ok || local == "" || domain == "" || strings.ContainsAny(domain, "/?#@") {
+		return nil, fmt.Errorf("%q is not an email address", email)
+	}
+	domain = strings.ToLower(domain)
+	sum := sha1.Sum([]byte(strings.ToLower(local)))
+	path := "/hu/" + zbase32.EncodeToString(sum[:]) + "?l=" + url.QueryEscape(local)
+	return []string{
+		"https://openpgpkey." + domain + "/.well-known/openpgpkey/" + domain + path,
+		"https://" + domain + "/.well-known/openpgpkey" + path,
+	}, nil
+}
+
+// FetchWKD looks up the public key for email by Web Key Directory, trying the
+// advanced method first and then the direct one.
+func (f *KeyFetcher) FetchWKD(ctx context.Context, email string) (string, error) {
+	urls, err := wkdURLs(email)
+	if err != nil {
+		return "", err
+	}
+	var errs []error
+	for _, u := range urls {
+		key, err := f.get(ctx, u)
+		if err == nil {
+			key, err = NormalizePublicKey(key)
+		}
+		if err == nil {
+			err = New(&Config{PGPPublicKey: key}).CanEncrypt()
+		}
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	return "", fmt.Errorf("wkd lookup for %s: %w", email, errors.Join(errs...))
+}
+
+// RefreshWKD sets s.PGPWKDKey from the Web Key Directory when s.PGPUseWKD is
+// set. Otherwise, or if the lookup fails, it clears it so RecipientKey falls
+// back to the manually configured key.
+func (f *KeyFetcher) RefreshWKD(ctx context.Context, s *model.AppSettings) error {
+	s.PGPWKDKey = ""
+	if !s.PGPUseWKD || s.DestinationEmail == "" {
+		return nil
+	}
+	key, err := f.FetchWKD(ctx, s.DestinationEmail)
+	if err != nil {
+		return err
+	}
+	s.PGPWKDKey = key
+	return nil
+}
@@ -0,0 +1,142 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/firewatch/internal/model"
+)
+
+func TestKeyFetcher(t *testing.T) {
+	pub, priv := generateTestKey(t)
+	const fp = "0123456789ABCDEF0123456789ABCDEF01234567"
+
+	var lookups []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pks/lookup":
+			lookups = append(lookups, r.URL.Query().Get("search"))
+			if s := r.URL.Query().Get("search"); s == "0x"+fp || s == "test@example.org" {
+				w.Write([]byte(pub)) //nolint:errcheck
+				return
+			}
+			http.NotFound(w, r)
+		case "/key.asc":
+			w.Write([]byte(pub)) //nolint:errcheck
+		case "/private.asc":
+			w.Write([]byte(priv)) //nolint:errcheck
+		case "/huge.asc":
+			w.Write([]byte(strings.Repeat("x", maxKeyBytes+1))) //nolint:errcheck
+		case "/garbage.asc":
+			w.Write([]byte("not a key")) //nolint:errcheck
+		case "/to-http":
+			http.Redirect(w, r, "http://example.org/key.asc", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewKeyFetcher()
+	f.Client.Transport = srv.Client().Transport
+	f.Keyserver = srv.URL
+
+	for _, source := range []string{srv.URL + "/key.asc", fp, strings.ToLower("0x" + fp), "test@example.org"} {
+		got, err := f.Fetch(context.Background(), source)
+		if err != nil {
+			t.Errorf("Fetch(%q): %v", source, err)
+		} else if got != pub {
+			t.Errorf("Fetch(%q) returned a different key", source)
+		}
+	}
+	if lookups[1] != "0x"+fp {
+		t.Errorf("fingerprint lookup searched %q, want it normalized", lookups[1])
+	}
+
+	for _, source := range []string{
+		"http://example.org/key.asc",
+		srv.URL + "/private.asc",
+		srv.URL + "/huge.asc",
+		srv.URL + "/garbage.asc",
+		srv.URL + "/to-http",
+		"nobody@example.org",
+		"not a source",
+	} {
+		if _, err := f.Fetch(context.Background(), source); err == nil {
+			t.Errorf("Fetch(%q) succeeded, want an error", source)
+		}
+	}
+
+	if _, err := f.Fetch(context.Background(), "nobody@example.org"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unknown email: got %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestWKDURLs(t *testing.T) {
+	// Test vector from draft-koch-openpgp-webkey-service.
+	urls, err := wkdURLs("Joe.Doe@Example.ORG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		"https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+	}
+	for i := range want {
+		if urls[i] != want[i] {
+			t.Errorf("url %d = %s, want %s", i, urls[i], want[i])
+		}
+	}
+	if _, err := wkdURLs("not-an-email"); err == nil {
+		t.Error("wkdURLs accepted a string without @")
+	}
+}
+
+func TestRefreshWKD(t *testing.T) {
+	pub, _ := generateTestKey(t)
+	manual, _ := generateTestKey(t)
+
+	// Only the direct method is published; the advanced host 404s.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "example.org" && strings.HasPrefix(r.URL.Path, "/.well-known/openpgpkey/hu/") {
+			w.Write([]byte(pub)) //nolint:errcheck
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	f := NewKeyFetcher()
+	f.Client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	s := &model.AppSettings{DestinationEmail: "reports@example.org", PGPKey: manual, PGPUseWKD: true}
+	if err := f.RefreshWKD(context.Background(), s); err != nil {
+		t.Fatalf("RefreshWKD: %v", err)
+	}
+	if s.RecipientKey() != pub {
+		t.Error("RecipientKey did not switch to the WKD key")
+	}
+
+	s.DestinationEmail = "reports@example.net"
+	if err := f.RefreshWKD(context.Background(), s); err == nil {
+		t.Fatal("RefreshWKD succeeded for a domain without WKD")
+	}
+	if s.RecipientKey() != manual {
+		t.Error("RecipientKey did not fall back to the configured key")
+	}
+
+	s.DestinationEmail = "reports@example.org"
+	s.PGPUseWKD = false
+	if err := f.RefreshWKD(context.Background(), s); err != nil || s.PGPWKDKey != "" {
+		t.Errorf("disabled RefreshWKD: err=%v, key kept=%t", err, s.PGPWKDKey != "")
+	}
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// isBinaryKey reports whether key is an unarmored OpenPGP export. Every
+// OpenPGP packet header has the high bit set, which armored text never has
+// in its first byte.
+func isBinaryKey(key string) bool {
+	return len(key) > 0 && key[0]&0x80 != 0
+}
+
+// readKeyRing parses key in either armored or binary (.gpg) form.
+func readKeyRing(key string) (openpgp.EntityList, error) {
+	if isBinaryKey(key) {
+		return openpgp.ReadKeyRing(strings.NewReader(key))
+	}
+	return openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+}
+
+// NormalizePublicKey returns key in armored form so it can be stored as text.
+// Armored keys are returned unchanged; binary keys are parsed and re-armored.
+// Binary private keys are rejected.
+func NormalizePublicKey(key string) (string, error) {
+	if !isBinaryKey(key) {
+		return key, nil
+	}
+	keyring, err := readKeyRing(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid PGP public key: %w", err)
+	}
+	if len(keyring) == 0 {
+		return "", errors.New("PGP key parsed but no keys found in keyring")
+	}
+
+	var buf strings.Builder
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range keyring {
+		if e.PrivateKey != nil {
+			return "", errors.New("private key detected — use the public key only")
+		}
+		if err := e.Serialize(w); err != nil {
+			return "", fmt.Errorf("pgp: armor public key: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("pgp: armor public key: %w", err)
+	}
+	buf.WriteString("\n")
+	return buf.String(), nil
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestBinaryAndArmoredPublicKeys(t *testing.T) {
+	pub, priv := generateTestKey(t)
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(pub))
+	if err != nil {
+		t.Fatalf("read test key: %v", err)
+	}
+	var bin bytes.Buffer
+	if err := keyring[0].Serialize(&bin); err != nil {
+		t.Fatalf("serialize binary key: %v", err)
+	}
+
+	for name, key := range map[string]string{"armored": pub, "binary": bin.String()} {
+		t.Run(name, func(t *testing.T) {
+			if err := New(&Config{PGPPublicKey: key}).CanEncrypt(); err != nil {
+				t.Errorf("CanEncrypt: %v", err)
+			}
+			if err := VerifyKeyPair(key, priv, ""); err != nil {
+				t.Errorf("VerifyKeyPair: %v", err)
+			}
+
+			normalized, err := NormalizePublicKey(key)
+			if err != nil {
+				t.Fatalf("NormalizePublicKey: %v", err)
+			}
+			if !strings.HasPrefix(normalized, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+				t.Errorf("normalized key is not armored: %.40q", normalized)
+			}
+			msg, err := encryptBody(normalized, "hello")
+			if err != nil {
+				t.Fatalf("encrypt to normalized key: %v", err)
+			}
+			if got := mustDecrypt(t, priv, msg); got != "hello" {
+				t.Errorf("decrypted %q, want %q", got, "hello")
+			}
+		})
+	}
+}
+
+func TestNormalizeRejectsBinaryPrivateKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatalf("generate test key: %v", err)
+	}
+	var bin bytes.Buffer
+	if err := entity.SerializePrivate(&bin, nil); err != nil {
+		t.Fatalf("serialize private key: %v", err)
+	}
+	if _, err := NormalizePublicKey(bin.String()); err == nil {
+		t.Error("binary private key was accepted")
+	}
+}
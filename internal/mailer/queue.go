@@ -2,8 +2,11 @@ package mailer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,28 +20,69 @@ type DeliveryRecorder interface {
 	Record(ctx context.Context, kind, status string)
 }
 
+// SendHealth describes the most recent SMTP delivery attempts, so admins can
+// see real delivery problems that a Ping alone would miss.
+type SendHealth struct {
+	LastSuccessAt time.Time
+	LastErrorAt   time.Time
+	LastError     string
+}
+
 type Queue struct {
 	mailer   *Mailer
 	ch       chan queuedMessage
+	rateMu   sync.Mutex
 	rate     time.Duration
+	rateCh   chan time.Duration
 	maxRetry int
 	recorder DeliveryRecorder // may be nil
+	breaker  *breaker
+
+	// retrying counts retries waiting out their backoff, so drain can wait
+	// for them to come back to the queue before it empties it.
+	retrying sync.WaitGroup
+
+	healthMu sync.Mutex
+	health   SendHealth
+
+	// Digest mode: when digestInterval is non-zero, encrypted report bodies
+	// are held in digest and sent together once the oldest has waited
+	// digestInterval or digestMax reports are waiting.
+	digestMu       sync.Mutex
+	digestInterval time.Duration
+	digestMax      int
+	digest         []string
+	digestSince    time.Time
 }
 
+// DefaultSendInterval is the pause between queued sends when the settings
+// don't set one.
+const DefaultSendInterval = time.Second
+
 func NewQueue(m *Mailer, rate time.Duration, bufferSize, maxRetry int, recorder DeliveryRecorder) *Queue {
 	return &Queue{
 		mailer:   m,
 		ch:       make(chan queuedMessage, bufferSize),
 		rate:     rate,
+		rateCh:   make(chan time.Duration, 1),
 		maxRetry: maxRetry,
 		recorder: recorder,
+		breaker:  newBreaker(breakerThreshold, breakerCooldown),
 	}
 }
 
+// BreakerState reports whether the queue is currently sending or paused
+// because the relay keeps failing.
+func (q *Queue) BreakerState() BreakerState {
+	return q.breaker.State()
+}
+
 // Start processes queued messages at the configured rate until ctx is cancelled.
 // On shutdown it drains any remaining messages before returning.
 func (q *Queue) Start(ctx context.Context) {
+	q.rateMu.Lock()
 	ticker := time.NewTicker(q.rate)
+	q.rateMu.Unlock()
 	defer ticker.Stop()
 
 	for {
@@ -46,17 +90,50 @@ func (q *Queue) Start(ctx context.Context) {
 		case <-ctx.Done():
 			q.drain()
 			return
+		case d := <-q.rateCh:
+			// Reset reuses the ticker, so nothing leaks and queued messages
+			// simply wait for the next tick at the new interval.
+			ticker.Reset(d)
 		case <-ticker.C:
+			q.flushDigest(false)
+
+			// While the breaker is open, leave messages queued rather than
+			// spending a full dial/TLS/auth cycle on a relay that is down.
+			if !q.breaker.allow() {
+				continue
+			}
 			select {
 			case item := <-q.ch:
 				q.attempt(ctx, item)
 			default:
 				// no message ready; wait for next tick
+				q.breaker.release()
 			}
 		}
 	}
 }
 
+// SetRate changes the interval between sends. It is safe to call before or
+// while Start is running; a running queue picks it up on its next loop.
+func (q *Queue) SetRate(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("mailer: send interval must be positive, got %s", d)
+	}
+	q.rateMu.Lock()
+	defer q.rateMu.Unlock()
+	if d == q.rate {
+		return nil
+	}
+	q.rate = d
+	// Keep only the newest pending change.
+	select {
+	case <-q.rateCh:
+	default:
+	}
+	q.rateCh <- d
+	return nil
+}
+
 // Enqueue adds a pre-encrypted message to the queue. Messages must already
 // have their body encrypted before enqueuing — see QueuedMailer.
 func (q *Queue) Enqueue(msg Message) error {
@@ -64,23 +141,75 @@ func (q *Queue) Enqueue(msg Message) error {
 	case q.ch <- queuedMessage{msg: msg}:
 		return nil
 	default:
-		return fmt.Errorf("mailer: queue full, message not queued")
+		return fmt.Errorf("%w: mailer: queue full, message not queued", ErrTransient)
 	}
 }
 
-// attempt sends a message, scheduling a context-aware retry with backoff on failure.
+// SendHealth returns the outcome of the most recent delivery attempts.
+func (q *Queue) SendHealth() SendHealth {
+	q.healthMu.Lock()
+	defer q.healthMu.Unlock()
+	return q.health
+}
+
+// SetSendHealth seeds the delivery health, e.g. from the delivery log at
+// startup, so it survives restarts.
+func (q *Queue) SetSendHealth(h SendHealth) {
+	q.healthMu.Lock()
+	q.health = h
+	q.healthMu.Unlock()
+}
+
+// send delivers one message and records the outcome in the send health.
+func (q *Queue) send(ctx context.Context, msg Message) error {
+	err := q.mailer.sendFn(ctx, msg)
+	now := time.Now()
+	q.healthMu.Lock()
+	if err == nil {
+		q.health.LastSuccessAt = now
+	} else {
+		q.health.LastErrorAt = now
+		q.health.LastError = err.Error()
+	}
+	q.healthMu.Unlock()
+	return err
+}
+
+// attempt sends a message, scheduling a context-aware retry with backoff on
+// a transient failure. Permanent failures (bad addresses, rejected
+// credentials, missing STARTTLS) would fail the same way again, so they are
+// recorded once and dropped without counting toward the breaker. ctx only
+// stops the queue: a send already under way is finished, bounded by
+// smtpTimeout, rather than cut off by shutdown.
 func (q *Queue) attempt(ctx context.Context, item queuedMessage) {
-	if err := q.mailer.send(item.msg); err == nil {
+	sendCtx := context.WithoutCancel(ctx)
+	err := q.send(sendCtx, item.msg)
+	if err == nil {
+		q.breaker.success()
 		if q.recorder != nil {
-			q.recorder.Record(ctx, "email", "ok")
+			q.recorder.Record(sendCtx, "email", "ok")
 		}
 		return
 	}
 
+	if !errors.Is(err, ErrTransient) {
+		q.breaker.release()
+		slog.Error("mailer: message dropped after permanent failure", "to", item.msg.To, "subject", item.msg.Subject, "err", err)
+		if q.recorder != nil {
+			q.recorder.Record(sendCtx, "email", "error")
+		}
+		return
+	}
+
+	q.breaker.failure()
+	if q.breaker.State() != BreakerClosed {
+		slog.Warn("mailer: SMTP circuit breaker open, pausing queue", "cooldown", q.breaker.cooldown, "err", err)
+	}
+
 	if item.retries >= q.maxRetry {
 		slog.Error("mailer: message dropped after max retries", "to", item.msg.To, "subject", item.msg.Subject)
 		if q.recorder != nil {
-			q.recorder.Record(ctx, "email", "error")
+			q.recorder.Record(sendCtx, "email", "error")
 		}
 		return
 	}
@@ -89,26 +218,34 @@ func (q *Queue) attempt(ctx context.Context, item queuedMessage) {
 	backoff := time.Duration(item.retries) * 5 * time.Second
 	slog.Warn("mailer: send failed, retrying with backoff", "to", item.msg.To, "subject", item.msg.Subject, "retry", item.retries, "backoff", backoff)
 
+	q.retrying.Add(1)
 	go func() {
+		defer q.retrying.Done()
 		select {
 		case <-time.After(backoff):
-			select {
-			case q.ch <- item:
-			default:
-				slog.Error("mailer: requeue failed, queue full, message dropped", "to", item.msg.To)
-			}
 		case <-ctx.Done():
-			slog.Warn("mailer: retry cancelled during shutdown", "to", item.msg.To)
+			// Shutting down: requeue now so drain sends it.
+		}
+		select {
+		case q.ch <- item:
+		default:
+			slog.Error("mailer: requeue failed, queue full, message dropped", "to", item.msg.To)
 		}
 	}()
 }
 
-// drain flushes remaining queued messages on shutdown, best-effort.
+// drain flushes remaining queued messages on shutdown, best-effort, once
+// pending retries are back in the queue and any collected digest has been
+// queued. The digest buffer lives only in memory, so reports still waiting in
+// it are lost if the process dies without a clean shutdown. Start's context
+// is already cancelled by then, so each send is bounded only by smtpTimeout.
 func (q *Queue) drain() {
+	q.retrying.Wait()
+	q.flushDigest(true)
 	for {
 		select {
 		case item := <-q.ch:
-			if err := q.mailer.send(item.msg); err != nil {
+			if err := q.send(context.Background(), item.msg); err != nil {
 				slog.Error("mailer: drain send failed", "to", item.msg.To, "err", err)
 			}
 		default:
@@ -121,46 +258,129 @@ func (q *Queue) drain() {
 // Implements ReportSender.
 func (q *Queue) SendReport(body string) error {
 	q.mailer.mu.RLock()
-	cfg := q.mailer.cfg
+	key := q.mailer.cfg.PGPPublicKey
 	q.mailer.mu.RUnlock()
 
-	if cfg.PGPPublicKey == "" {
-		return fmt.Errorf("PGP public key is not configured")
+	if key == "" {
+		return ErrPGPNotConfigured
 	}
 
-	encrypted, err := encryptBody(cfg.PGPPublicKey, body)
+	msg, err := q.mailer.reportMessage(body)
 	if err != nil {
-		return fmt.Errorf("encrypt report: %w", err)
+		return err
+	}
+	encrypted, err := encryptBody(key, msg.Body)
+	if err != nil {
+		return fmt.Errorf("%w: encrypt report: %w", ErrEncrypt, err)
+	}
+
+	if q.addToDigest(encrypted) {
+		return nil
+	}
+
+	msg.Body = encrypted
+	if err := q.Enqueue(msg); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotSent, err)
+	}
+	return nil
+}
+
+// SetDigest configures digest mode. An interval of zero sends every report
+// immediately (the default); otherwise reports are batched until the oldest
+// has waited interval or maxBatch are waiting. maxBatch <= 0 means no cap.
+func (q *Queue) SetDigest(interval time.Duration, maxBatch int) {
+	q.digestMu.Lock()
+	q.digestInterval = interval
+	q.digestMax = maxBatch
+	q.digestMu.Unlock()
+}
+
+// addToDigest holds an already-encrypted report body for the next digest.
+// It returns false when digest mode is off and the caller should send now.
+func (q *Queue) addToDigest(encrypted string) bool {
+	q.digestMu.Lock()
+	if q.digestInterval <= 0 {
+		q.digestMu.Unlock()
+		return false
+	}
+	if len(q.digest) == 0 {
+		q.digestSince = time.Now()
 	}
+	q.digest = append(q.digest, encrypted)
+	full := q.digestMax > 0 && len(q.digest) >= q.digestMax
+	q.digestMu.Unlock()
 
-	return q.Enqueue(Message{
-		To:      cfg.To,
-		Subject: "Report from Firewatch",
-		Body:    encrypted,
+	if full {
+		q.flushDigest(true)
+	}
+	return true
+}
+
+// flushDigest enqueues the held reports as one email if the digest is due,
+// or unconditionally when force is set. Reports held when digest mode was
+// switched off are flushed on the next call.
+func (q *Queue) flushDigest(force bool) {
+	q.digestMu.Lock()
+	due := force || q.digestInterval <= 0 || time.Since(q.digestSince) >= q.digestInterval
+	if len(q.digest) == 0 || !due {
+		q.digestMu.Unlock()
+		return
+	}
+	bodies := q.digest
+	q.digest = nil
+	q.digestMu.Unlock()
+
+	q.mailer.mu.RLock()
+	to := q.mailer.cfg.To
+	q.mailer.mu.RUnlock()
+
+	if err := q.Enqueue(Message{
+		To:      to,
+		Subject: fmt.Sprintf("Firewatch digest: %d report(s)", len(bodies)),
+		Body:    formatDigest(bodies),
 		IsHTML:  false,
-	})
+	}); err != nil {
+		slog.Error("mailer: digest dropped", "reports", len(bodies), "err", err)
+	}
+}
+
+// formatDigest joins individually encrypted reports, each kept as its own
+// PGP block so they can be decrypted one at a time.
+func formatDigest(bodies []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d report(s). Each is a separate PGP message.\n", len(bodies))
+	for i, body := range bodies {
+		fmt.Fprintf(&b, "\n----- Report %d of %d -----\n\n%s\n", i+1, len(bodies), strings.TrimRight(body, "\n"))
+	}
+	return b.String()
 }
 
 // SendInvite constructs an invite email then enqueues it.
 func (q *Queue) SendInvite(to, inviteURL string) error {
-	return q.Enqueue(Message{
-		To:      []string{to},
-		Subject: "You've been invited to Firewatch",
-		Body: fmt.Sprintf(
-			"You have been invited to access Firewatch.\n\nAccept your invitation:\n%s\n\nThis link expires in 48 hours.",
-			inviteURL,
-		),
-		IsHTML:  true,
-	})
+	msg, err := activeEmailTemplates.Load().inviteMessage(to, inviteURL)
+	if err != nil {
+		return err
+	}
+	return q.Enqueue(msg)
 }
 
 // Ping delegates to the underlying Mailer.
-func (q *Queue) Ping() error {
-	return q.mailer.Ping()
+func (q *Queue) Ping(ctx context.Context) error {
+	return q.mailer.Ping(ctx)
 }
 
+// Reconfigure updates the mailer, digest mode and the send interval, which
+// goes back to DefaultSendInterval when cfg doesn't set one.
 func (q *Queue) Reconfigure(cfg *Config) {
 	q.mailer.Reconfigure(cfg)
+	q.SetDigest(cfg.DigestInterval, cfg.DigestMaxBatch)
+	interval := DefaultSendInterval
+	if cfg.SendInterval > 0 {
+		interval = cfg.SendInterval
+	}
+	if err := q.SetRate(interval); err != nil {
+		slog.Error("mailer: failed to set send interval", "err", err)
+	}
 }
 
 // CanEncrypt delegates to the underlying Mailer.
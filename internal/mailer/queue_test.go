@@ -1 +1,254 @@
 package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueSetRateWhileRunning(t *testing.T) {
+	sent := make(chan Message, 4)
+	m := New(&Config{})
+	q := NewQueue(m, time.Hour, 4, 0, nil)
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		sent <- msg
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Start(ctx)
+
+	if err := q.Enqueue(Message{Subject: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sent:
+		t.Fatal("message sent before the hourly tick")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := q.SetRate(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-sent:
+		if msg.Subject != "one" {
+			t.Errorf("sent %q, want %q", msg.Subject, "one")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not sent after speeding up the queue")
+	}
+
+	if err := q.SetRate(0); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+}
+
+func TestQueueDigestBatchesEncryptedReports(t *testing.T) {
+	pubKey, privKey := generateTestKey(t)
+	m := New(&Config{To: []string{"admin@example.org"}, PGPPublicKey: pubKey})
+	q := NewQueue(m, time.Hour, 4, 0, nil)
+	q.SetDigest(time.Hour, 2)
+
+	if err := q.SendReport("first"); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.ch) != 0 {
+		t.Fatal("report sent before the digest was full")
+	}
+	if err := q.SendReport("second"); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.ch) != 1 {
+		t.Fatalf("queued %d messages, want 1 digest", len(q.ch))
+	}
+
+	digest := (<-q.ch).msg
+	if !strings.Contains(digest.Subject, "2 report(s)") {
+		t.Errorf("subject = %q", digest.Subject)
+	}
+	blocks := strings.Split(digest.Body, "-----BEGIN PGP MESSAGE-----")
+	if len(blocks) != 3 {
+		t.Fatalf("digest has %d PGP blocks, want 2:\n%s", len(blocks)-1, digest.Body)
+	}
+	for i, want := range []string{"first", "second"} {
+		armored := "-----BEGIN PGP MESSAGE-----" + strings.SplitAfter(blocks[i+1], "-----END PGP MESSAGE-----")[0]
+		if got := mustDecrypt(t, privKey, armored); got != want {
+			t.Errorf("report %d decrypted to %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestQueueDigestOffSendsImmediately(t *testing.T) {
+	pubKey, _ := generateTestKey(t)
+	q := NewQueue(New(&Config{To: []string{"admin@example.org"}, PGPPublicKey: pubKey}), time.Hour, 4, 0, nil)
+
+	if err := q.SendReport("only"); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.ch) != 1 {
+		t.Fatalf("queued %d messages, want 1", len(q.ch))
+	}
+}
+
+func TestQueueSendHealth(t *testing.T) {
+	m := New(&Config{})
+	q := NewQueue(m, time.Hour, 4, 0, nil)
+	fail := errors.New("451 try again later")
+	var next error
+	m.sendFn = func(ctx context.Context, msg Message) error { return next }
+
+	if h := q.SendHealth(); !h.LastSuccessAt.IsZero() || !h.LastErrorAt.IsZero() {
+		t.Fatalf("fresh queue has health %+v", h)
+	}
+
+	q.attempt(context.Background(), queuedMessage{msg: Message{Subject: "ok"}})
+	next = fail
+	q.attempt(context.Background(), queuedMessage{msg: Message{Subject: "bad"}})
+
+	h := q.SendHealth()
+	if h.LastSuccessAt.IsZero() {
+		t.Error("LastSuccessAt not set after a successful send")
+	}
+	if h.LastErrorAt.Before(h.LastSuccessAt) || h.LastError != fail.Error() {
+		t.Errorf("failure not recorded: %+v", h)
+	}
+}
+
+func TestQueueShutdownFinishesSendInProgress(t *testing.T) {
+	m := New(&Config{})
+	q := NewQueue(m, 10*time.Millisecond, 4, 0, nil)
+	started, release := make(chan struct{}), make(chan struct{})
+	var sendErr error
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		close(started)
+		<-release
+		sendErr = ctx.Err()
+		return sendErr
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { q.Start(ctx); close(done) }()
+	if err := q.Enqueue(Message{Subject: "mid-send"}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	cancel()
+	close(release)
+	<-done
+
+	if sendErr != nil {
+		t.Errorf("shutdown aborted a send in progress: %v", sendErr)
+	}
+	if h := q.SendHealth(); h.LastSuccessAt.IsZero() {
+		t.Errorf("send in progress at shutdown not delivered: %+v", h)
+	}
+}
+
+func TestQueueShutdownDeliversPendingRetry(t *testing.T) {
+	m := New(&Config{})
+	q := NewQueue(m, 10*time.Millisecond, 4, 3, nil)
+	failed := make(chan struct{})
+	var calls int
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		calls++
+		if calls == 1 {
+			close(failed)
+			return fmt.Errorf("%w: 451 try again later", ErrTransient)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { q.Start(ctx); close(done) }()
+	if err := q.Enqueue(Message{Subject: "retry"}); err != nil {
+		t.Fatal(err)
+	}
+	<-failed
+	// The retry now waits out a 5s backoff; shutdown must not drop it.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown waited for the retry backoff")
+	}
+	if calls != 2 {
+		t.Errorf("sent %d times, want the pending retry delivered by drain", calls)
+	}
+}
+
+func TestQueueReconfigureZeroIntervalRestoresDefault(t *testing.T) {
+	q := NewQueue(New(&Config{}), DefaultSendInterval, 4, 0, nil)
+
+	q.Reconfigure(&Config{SendInterval: 30 * time.Second})
+	q.Reconfigure(&Config{})
+	q.rateMu.Lock()
+	got := q.rate
+	q.rateMu.Unlock()
+	if got != DefaultSendInterval {
+		t.Errorf("rate after clearing the interval = %s, want %s", got, DefaultSendInterval)
+	}
+}
+
+func TestQueuePermanentFailuresDoNotTripBreaker(t *testing.T) {
+	m := New(&Config{})
+	q := NewQueue(m, time.Hour, 4, 0, nil)
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		return errors.New("550 no such user")
+	}
+
+	for i := 0; i < breakerThreshold; i++ {
+		q.attempt(context.Background(), queuedMessage{msg: Message{Subject: "bad address"}})
+	}
+	if s := q.BreakerState(); s != BreakerClosed {
+		t.Errorf("breaker %s after permanent failures, want %s", s, BreakerClosed)
+	}
+}
+
+func TestQueueDoesNotRetryPermanentFailure(t *testing.T) {
+	m := New(&Config{})
+	q := NewQueue(m, 10*time.Millisecond, 4, 3, nil)
+	var calls int
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		calls++
+		return errors.New("535 authentication failed")
+	}
+
+	q.attempt(context.Background(), queuedMessage{msg: Message{Subject: "rejected"}})
+	q.retrying.Wait()
+	if calls != 1 {
+		t.Errorf("sent %d times, want exactly one attempt for a permanent failure", calls)
+	}
+	if len(q.ch) != 0 {
+		t.Errorf("permanent failure requeued %d messages", len(q.ch))
+	}
+}
+
+func TestQueueShutdownSendsPendingDigest(t *testing.T) {
+	pubKey, _ := generateTestKey(t)
+	m := New(&Config{To: []string{"admin@example.org"}, PGPPublicKey: pubKey})
+	q := NewQueue(m, time.Hour, 4, 0, nil)
+	q.SetDigest(time.Hour, 0)
+	var sent []Message
+	m.sendFn = func(ctx context.Context, msg Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	if err := q.SendReport("waiting"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.Start(ctx)
+
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Subject, "Firewatch digest: 1 report") {
+		t.Fatalf("sent %+v at shutdown, want the pending digest", sent)
+	}
+}
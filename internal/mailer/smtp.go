@@ -2,18 +2,39 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/firewatch/internal/model"
 )
 
+// Errors returned by SendReport and CanEncrypt. ErrPGPNotConfigured is
+// permanent until an admin fixes the settings; ErrEncrypt wraps a failure to
+// encrypt with the configured key; ErrNotSent wraps a failure to send or
+// queue a report that was already encrypted; ErrTransient wraps failures that
+// may succeed if retried, such as a network error or a 4xx reply.
+var (
+	ErrPGPNotConfigured = errors.New("no PGP public key configured")
+	ErrEncrypt          = errors.New("pgp encryption failed")
+	ErrNotSent          = errors.New("encrypted report not sent")
+	ErrTransient        = errors.New("temporary mail delivery failure")
+)
+
 // ReportSender sends form submission emails to assigned address.
 type ReportSender interface {
 	SendReport(body string) error
@@ -27,10 +48,21 @@ type InviteSender interface {
 
 // PingSender sends test emails to verify mailer configuration.
 type PingSender interface {
-	Ping() error
+	Ping(ctx context.Context) error
 	Reconfigure(cfg *Config)
 }
 
+// Handlers depend on the narrow interfaces above, never on a concrete type,
+// so the direct mailer and the queue are interchangeable.
+var (
+	_ ReportSender = (*Mailer)(nil)
+	_ ReportSender = (*Queue)(nil)
+	_ InviteSender = (*Mailer)(nil)
+	_ InviteSender = (*Queue)(nil)
+	_ PingSender   = (*Mailer)(nil)
+	_ PingSender   = (*Queue)(nil)
+)
+
 type Message struct {
 	To          []string
 	Subject     string
@@ -54,14 +86,38 @@ type Config struct {
 	FromAddress  string
 	To           []string
 	PGPPublicKey string
+
+	// AllowInsecureSMTP skips the STARTTLS requirement for relays that don't
+	// offer it, e.g. a localhost sidecar that handles TLS upstream. Off by default.
+	AllowInsecureSMTP bool
+
+	// CACertPEM, when set, replaces the system root pool for verifying the
+	// relay's certificate (internal CA or self-signed).
+	CACertPEM string
+
+	// InsecureSkipVerify disables certificate verification entirely. Off by default.
+	InsecureSkipVerify bool
+
+	// SendInterval is the pause between queued sends. Zero means
+	// DefaultSendInterval.
+	SendInterval time.Duration
+
+	// DigestInterval batches reports into one email per interval; zero sends
+	// each report immediately. DigestMaxBatch caps a batch (zero: no cap).
+	DigestInterval time.Duration
+	DigestMaxBatch int
 }
 
 type Mailer struct {
 	mu     sync.RWMutex
 	cfg    *Config
-	sendFn func(msg Message) error
+	sendFn func(ctx context.Context, msg Message) error
 }
 
+// smtpTimeout bounds a whole SMTP conversation (dial through DATA), so a
+// relay that stops responding can't hold a request or the queue forever.
+const smtpTimeout = 30 * time.Second
+
 func New(cfg *Config) *Mailer {
 	m := &Mailer{cfg: cfg}
 	m.sendFn = m.send
@@ -76,43 +132,134 @@ func (m *Mailer) Reconfigure(cfg *Config) {
 }
 
 // formatMessage constructs the raw email message string from the Message struct.
+// Every header value has CR and LF removed so it can't start a new header.
 func (m *Mailer) formatMessage(msg Message) string {
+	to := make([]string, len(msg.To))
+	for i, addr := range msg.To {
+		to[i] = headerValue(addr)
+	}
 	return fmt.Sprintf(
 		"From: %s <%s>\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
-		m.cfg.FromName,
-		m.cfg.FromAddress,
-		strings.Join(msg.To, ", "),
-		msg.Subject,
+		headerValue(m.cfg.FromName),
+		headerValue(m.cfg.FromAddress),
+		strings.Join(to, ", "),
+		headerValue(msg.Subject),
 		msg.Body,
 	)
 }
 
-// send sends an email message over SMTP with mandatory STARTTLS.
-func (m *Mailer) send(msg Message) error {
+// headerValue makes s safe to place in a single header line by replacing any
+// CR or LF with a space.
+func headerValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// validateAddress checks that addr is a single bare email address, as expected
+// by the SMTP MAIL and RCPT commands.
+func validateAddress(addr string) error {
+	if strings.ContainsAny(addr, "\r\n") {
+		return fmt.Errorf("invalid email address %q: contains a line break", addr)
+	}
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", addr, err)
+	}
+	if parsed.Address != addr {
+		return fmt.Errorf("invalid email address %q: expected a bare address", addr)
+	}
+	return nil
+}
+
+// dialSMTP connects to addr and returns a client whose connection is closed
+// as soon as ctx is done or smtpTimeout passes, which aborts whatever SMTP
+// command is in flight. The caller must call stop when done with the client.
+func dialSMTP(ctx context.Context, host, addr string) (client *smtp.Client, stop func(), err error) {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+	stop = func() {
+		stopClose()
+		cancel()
+	}
+
+	client, err = smtp.NewClient(conn, host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, err
+	}
+	return client, stop, nil
+}
+
+// transient wraps err with ErrTransient when it is a network failure or a
+// 4xx SMTP reply, both of which are worth retrying. Other errors (bad
+// addresses, missing STARTTLS, rejected credentials) are returned unchanged.
+func transient(err error) error {
+	var tpErr *textproto.Error
+	var netErr net.Error
+	if (errors.As(err, &tpErr) && tpErr.Code/100 == 4) || errors.As(err, &netErr) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	return err
+}
+
+// send sends an email message over SMTP with mandatory STARTTLS, unless
+// AllowInsecureSMTP is set. Authentication is skipped when no user is configured.
+// Retryable failures wrap ErrTransient.
+func (m *Mailer) send(ctx context.Context, msg Message) (err error) {
+	defer func() { err = transient(err) }()
+
 	m.mu.RLock()
 	cfg := m.cfg
 	m.mu.RUnlock()
 
+	if err := validateAddress(cfg.FromAddress); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	for _, recipient := range msg.To {
+		if err := validateAddress(recipient); err != nil {
+			return fmt.Errorf("recipient: %w", err)
+		}
+	}
+
 	auth := smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 
-	client, err := smtp.Dial(addr)
+	client, stop, err := dialSMTP(ctx, cfg.Host, addr)
 	if err != nil {
 		return fmt.Errorf("dial %s: %w", addr, err)
 	}
+	defer stop()
 	defer client.Close()
 
-	if ok, _ := client.Extension("STARTTLS"); !ok {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return err
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	} else if cfg.AllowInsecureSMTP {
+		slog.Warn("mailer: SMTP server does not support STARTTLS, sending without TLS (insecure SMTP allowed)", "host", cfg.Host)
+	} else {
 		return fmt.Errorf("SMTP server does not support STARTTLS")
 	}
 
-	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
-	if err := client.StartTLS(tlsConfig); err != nil {
-		return fmt.Errorf("STARTTLS: %w", err)
-	}
-
-	if err := client.Auth(auth); err != nil {
-		return fmt.Errorf("auth: %w", err)
+	if cfg.User != "" {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
 	}
 
 	if err := client.Mail(cfg.FromAddress); err != nil {
@@ -129,34 +276,63 @@ func (m *Mailer) send(msg Message) error {
 	if err != nil {
 		return fmt.Errorf("get data writer: %w", err)
 	}
-	defer wc.Close()
-
 	if _, err := wc.Write([]byte(m.formatMessage(msg))); err != nil {
+		wc.Close()
 		return fmt.Errorf("write message: %w", err)
 	}
-
+	// Close ends the DATA command and returns the relay's verdict on the
+	// message, which is where it accepts or rejects it.
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("send data: %w", err)
+	}
+	// The message is accepted by now; a failed QUIT must not get it retried.
+	_ = client.Quit()
 	return nil
 }
 
+// newTLSConfig builds the STARTTLS client config, trusting cfg.CACertPEM in
+// place of the system roots when it is set.
+func newTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+
+	if cfg.CACertPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACertPEM)) {
+			return nil, fmt.Errorf("invalid SMTP CA certificate: no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.InsecureSkipVerify {
+		slog.Warn("mailer: TLS certificate verification is DISABLED for SMTP — connections can be intercepted", "host", cfg.Host)
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec // explicit admin opt-in
+	}
+
+	return tlsConfig, nil
+}
+
 // sendEncrypted encrypts msg.Body with the configured PGP key then sends it.
-func (m *Mailer) sendEncrypted(msg Message) error {
+func (m *Mailer) sendEncrypted(ctx context.Context, msg Message) error {
 	m.mu.RLock()
 	key := m.cfg.PGPPublicKey
 	m.mu.RUnlock()
 
 	if key == "" {
-		return fmt.Errorf("PGP public key is not configured")
+		return ErrPGPNotConfigured
 	}
 
 	encrypted, err := encryptBody(key, msg.Body)
 	if err != nil {
-		return fmt.Errorf("encrypt message body: %w", err)
+		return fmt.Errorf("%w: encrypt message body: %w", ErrEncrypt, err)
 	}
 
 	msg.Body = encrypted
 	msg.IsHTML = false
 
-	return m.sendFn(msg)
+	if err := m.sendFn(ctx, msg); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotSent, err)
+	}
+	return nil
 }
 
 // CanEncrypt validates that the configured PGP public key is non-empty and parseable.
@@ -166,10 +342,10 @@ func (m *Mailer) CanEncrypt() error {
 	m.mu.RUnlock()
 
 	if key == "" {
-		return fmt.Errorf("no PGP public key configured")
+		return ErrPGPNotConfigured
 	}
 
-	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	keyring, err := readKeyRing(key)
 	if err != nil {
 		return fmt.Errorf("invalid PGP public key: %w", err)
 	}
@@ -187,9 +363,90 @@ func (m *Mailer) CanEncrypt() error {
 	return nil
 }
 
+// decryptSelfTestText is the plaintext round-tripped by VerifyKeyPair.
+const decryptSelfTestText = "firewatch decrypt self-test"
+
+// VerifyKeyPair encrypts a known string to publicKey and checks that
+// privateKey (unlocked with passphrase if it is protected) decrypts it. Nothing
+// is stored; the private key only lives for the duration of the call.
+func VerifyKeyPair(publicKey, privateKey, passphrase string) error {
+	if publicKey == "" {
+		return ErrPGPNotConfigured
+	}
+	encrypted, err := encryptBody(publicKey, decryptSelfTestText)
+	if err != nil {
+		return err
+	}
+
+	plain, err := DecryptBody(privateKey, passphrase, encrypted)
+	if err != nil {
+		return err
+	}
+	if plain != decryptSelfTestText {
+		return fmt.Errorf("pgp: decrypted text does not match")
+	}
+	return nil
+}
+
+// DecryptBody decrypts an ASCII-armored PGP message such as the body of a
+// report, using privateKey unlocked with passphrase if it is protected. It is
+// the receiving side of encryptBody.
+func DecryptBody(privateKey, passphrase, armoredMsg string) (string, error) {
+	keyring, err := readKeyRing(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("pgp: read private key: %w", err)
+	}
+	if err := unlockKeyRing(keyring, passphrase); err != nil {
+		return "", err
+	}
+
+	block, err := armor.Decode(strings.NewReader(armoredMsg))
+	if err != nil {
+		return "", fmt.Errorf("pgp: decode armor: %w", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("pgp: decrypt: %w", err)
+	}
+	plain, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", fmt.Errorf("pgp: read decrypted body: %w", err)
+	}
+	return string(plain), nil
+}
+
+// unlockKeyRing decrypts every passphrase-protected private key and subkey in
+// keyring. A protected key with no passphrase is an error rather than a
+// confusing failure later on.
+func unlockKeyRing(keyring openpgp.EntityList, passphrase string) error {
+	unlock := func(k *packet.PrivateKey, what string) error {
+		if k == nil || !k.Encrypted {
+			return nil
+		}
+		if passphrase == "" {
+			return fmt.Errorf("pgp: %s is passphrase-protected", what)
+		}
+		if err := k.Decrypt([]byte(passphrase)); err != nil {
+			return fmt.Errorf("pgp: unlock %s: %w", what, err)
+		}
+		return nil
+	}
+	for _, e := range keyring {
+		if err := unlock(e.PrivateKey, "private key"); err != nil {
+			return err
+		}
+		for _, sub := range e.Subkeys {
+			if err := unlock(sub.PrivateKey, "private subkey"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // encryptBody encrypts plainText for publicKey and returns an ASCII-armored PGP message.
 func encryptBody(publicKey, plainText string) (string, error) {
-	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	keyring, err := readKeyRing(publicKey)
 	if err != nil {
 		return "", fmt.Errorf("pgp: read recipient key: %w", err)
 	}
@@ -224,9 +481,42 @@ func encryptBody(publicKey, plainText string) (string, error) {
 	return buf.String(), nil
 }
 
+// PingStage identifies which step of an SMTP connection check failed.
+type PingStage string
+
+const (
+	StageConnect PingStage = "connect" // host unreachable or refused the connection
+	StageTLS     PingStage = "tls"     // STARTTLS missing, handshake or certificate failure
+	StageAuth    PingStage = "auth"    // credentials rejected
+)
+
+// PingError is returned by Ping and records the stage that failed.
+type PingError struct {
+	Stage PingStage
+	Err   error
+}
+
+func (e *PingError) Error() string { return e.Err.Error() }
+
+func (e *PingError) Unwrap() error { return e.Err }
+
+// Retryable reports whether the failure may be transient. Connection errors
+// can clear up on their own; TLS and auth failures need a configuration change.
+func (e *PingError) Retryable() bool { return e.Stage == StageConnect }
+
+// ErrorStage returns the stage recorded in a PingError, or "" if err is not one.
+func ErrorStage(err error) PingStage {
+	var pe *PingError
+	if errors.As(err, &pe) {
+		return pe.Stage
+	}
+	return ""
+}
+
 // Ping connects and authenticates with the SMTP server to verify configuration.
-// It requires STARTTLS — consistent with the enforcement in send().
-func (m *Mailer) Ping() error {
+// It requires STARTTLS unless AllowInsecureSMTP is set — consistent with send().
+// Cancelling ctx closes the connection and aborts the check.
+func (m *Mailer) Ping(ctx context.Context) error {
 	m.mu.RLock()
 	cfg := m.cfg
 	m.mu.RUnlock()
@@ -234,23 +524,31 @@ func (m *Mailer) Ping() error {
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	auth := smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
 
-	client, err := smtp.Dial(addr)
+	client, stop, err := dialSMTP(ctx, cfg.Host, addr)
 	if err != nil {
-		return fmt.Errorf("mailer ping: dial %s: %w", addr, err)
+		return &PingError{Stage: StageConnect, Err: fmt.Errorf("mailer ping: dial %s: %w", addr, err)}
 	}
+	defer stop()
 	defer client.Close()
 
-	if ok, _ := client.Extension("STARTTLS"); !ok {
-		return fmt.Errorf("SMTP server does not support STARTTLS")
-	}
-
-	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
-	if err := client.StartTLS(tlsConfig); err != nil {
-		return fmt.Errorf("mailer ping: STARTTLS: %w", err)
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return &PingError{Stage: StageTLS, Err: fmt.Errorf("mailer ping: %w", err)}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return &PingError{Stage: StageTLS, Err: fmt.Errorf("mailer ping: STARTTLS: %w", err)}
+		}
+	} else if cfg.AllowInsecureSMTP {
+		slog.Warn("mailer ping: SMTP server does not support STARTTLS, continuing without TLS (insecure SMTP allowed)", "host", cfg.Host)
+	} else {
+		return &PingError{Stage: StageTLS, Err: fmt.Errorf("SMTP server does not support STARTTLS")}
 	}
 
-	if err := client.Auth(auth); err != nil {
-		return fmt.Errorf("mailer ping: auth: %w", err)
+	if cfg.User != "" {
+		if err := client.Auth(auth); err != nil {
+			return &PingError{Stage: StageAuth, Err: fmt.Errorf("mailer ping: auth: %w", err)}
+		}
 	}
 
 	return nil
@@ -258,41 +556,73 @@ func (m *Mailer) Ping() error {
 
 // SendInvite emails an invitation link directly to the invitee.
 func (m *Mailer) SendInvite(toEmail, inviteURL string) error {
-	return m.sendFn(Message{
-		To:      []string{toEmail},
-		Subject: "You've been invited to Firewatch",
-		Body: fmt.Sprintf(
-			"You have been invited to access Firewatch.\n\nAccept your invitation:\n%s\n\nThis link expires in 48 hours.",
-			inviteURL,
-		),
-		IsHTML: false,
-	})
+	msg, err := activeEmailTemplates.Load().inviteMessage(toEmail, inviteURL)
+	if err != nil {
+		return err
+	}
+	return m.sendFn(context.Background(), msg)
 }
 
 // SendReport encrypts body with PGP and sends it to the configured destination(s).
 func (m *Mailer) SendReport(body string) error {
+	msg, err := m.reportMessage(body)
+	if err != nil {
+		return err
+	}
+	return m.sendEncrypted(context.Background(), msg)
+}
+
+// PreviewReport returns the exact message SendReport would transmit for body,
+// encrypted and formatted, without connecting to the relay.
+func (m *Mailer) PreviewReport(body string) ([]byte, error) {
+	msg, err := m.reportMessage(body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	key := m.cfg.PGPPublicKey
+	m.mu.RUnlock()
+
+	if key == "" {
+		return nil, ErrPGPNotConfigured
+	}
+	encrypted, err := encryptBody(key, msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt message body: %w", err)
+	}
+	msg.Body = encrypted
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return []byte(m.formatMessage(msg)), nil
+}
+
+// reportMessage renders the unencrypted report email for body.
+func (m *Mailer) reportMessage(body string) (Message, error) {
 	m.mu.RLock()
 	to := m.cfg.To
 	m.mu.RUnlock()
 
-	return m.sendEncrypted(Message{
-		To:      to,
-		Subject: "Report from Firewatch",
-		Body:    body,
-		IsHTML:  false,
-	})
+	return activeEmailTemplates.Load().reportMessage(to, body)
 }
 
 // NewConfigFromSettings creates a mailer Config from application settings.
 func NewConfigFromSettings(s *model.AppSettings) *Config {
 	return &Config{
-		Host:         s.SMTPHost,
-		Port:         s.SMTPPort,
-		User:         s.SMTPUser,
-		Pass:         s.SMTPPass,
-		FromName:     s.SMTPFromName,
-		FromAddress:  s.SMTPFromAddress,
-		To:           []string{s.DestinationEmail},
-		PGPPublicKey: s.PGPKey,
+		Host:               s.SMTPHost,
+		Port:               s.SMTPPort,
+		User:               s.SMTPUser,
+		Pass:               s.SMTPPass,
+		FromName:           s.SMTPFromName,
+		FromAddress:        s.SMTPFromAddress,
+		To:                 []string{s.DestinationEmail},
+		PGPPublicKey:       s.RecipientKey(),
+		AllowInsecureSMTP:  s.SMTPAllowInsecure,
+		CACertPEM:          s.SMTPCACert,
+		InsecureSkipVerify: s.SMTPSkipVerify,
+		SendInterval:       time.Duration(s.MailSendInterval) * time.Second,
+		DigestInterval:     time.Duration(s.DigestInterval) * time.Minute,
+		DigestMaxBatch:     s.DigestMaxBatch,
 	}
 }
@@ -1,9 +1,22 @@
 package mailer
 
 import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
 	"io"
+	"math/big"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
@@ -58,10 +71,43 @@ func TestFormatMessageWithMultipleRecipients(t *testing.T) {
 	}
 }
 
+func TestFormatMessageStripsHeaderInjection(t *testing.T) {
+	cfg := &Config{FromName: "Firewatch\r\nReply-To: evil@example.com", FromAddress: "noreply@example.org"}
+	msg := Message{
+		To:      []string{"user@example.org"},
+		Subject: "Report\r\nBcc: evil@example.com\n\nInjected body",
+		Body:    "real body",
+	}
+
+	result := New(cfg).formatMessage(msg)
+	headers, body, _ := strings.Cut(result, "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "Reply-To:") {
+			t.Errorf("injected header line %q in:\n%s", line, result)
+		}
+	}
+	if strings.ContainsAny(headers, "\n") && strings.Count(headers, "\n") != strings.Count(headers, "\r\n") {
+		t.Errorf("bare LF in headers:\n%q", headers)
+	}
+	if body != "real body" {
+		t.Errorf("body = %q, want %q", body, "real body")
+	}
+}
+
+func TestSendRejectsInvalidRecipient(t *testing.T) {
+	m := New(&Config{Host: "127.0.0.1", Port: 1, FromAddress: "noreply@example.org"})
+	for _, to := range []string{"a@example.org\r\nRCPT TO:<evil@example.com>", "Name <a@example.org>", "not-an-address"} {
+		err := m.send(context.Background(), Message{To: []string{to}, Subject: "hi", Body: "body"})
+		if err == nil || !strings.Contains(err.Error(), "invalid email address") {
+			t.Errorf("send to %q: got %v, want invalid address error", to, err)
+		}
+	}
+}
+
 func captureSend(t *testing.T, m *Mailer) *Message {
 	t.Helper()
 	var captured Message
-	m.sendFn = func(msg Message) error {
+	m.sendFn = func(ctx context.Context, msg Message) error {
 		captured = msg
 		return nil
 	}
@@ -165,6 +211,95 @@ func TestSendEncryptedReport(t *testing.T) {
 	}
 }
 
+func TestPreviewReport(t *testing.T) {
+	pubKey, privKey := generateTestKey(t)
+	m := New(&Config{
+		Host:         "127.0.0.1",
+		Port:         1,
+		FromAddress:  "noreply@example.org",
+		FromName:     "Firewatch",
+		To:           []string{"admin@example.org"},
+		PGPPublicKey: pubKey,
+	})
+	m.sendFn = func(context.Context, Message) error {
+		t.Fatal("PreviewReport must not send")
+		return nil
+	}
+
+	raw, err := m.PreviewReport("Sensitive info")
+	if err != nil {
+		t.Fatalf("preview report error: %v", err)
+	}
+
+	headers, body, ok := strings.Cut(string(raw), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("preview has no header/body separator:\n%s", raw)
+	}
+	if !strings.Contains(headers, "To: admin@example.org") || !strings.Contains(headers, "Subject: Report from Firewatch") {
+		t.Errorf("unexpected headers:\n%s", headers)
+	}
+	if got := mustDecrypt(t, privKey, body); got != "Sensitive info" {
+		t.Errorf("decrypted preview = %q, want %q", got, "Sensitive info")
+	}
+}
+
+func TestPreviewReportRequiresKey(t *testing.T) {
+	m := New(&Config{To: []string{"admin@example.org"}})
+	if _, err := m.PreviewReport("x"); err == nil {
+		t.Error("expected error without a PGP key")
+	}
+}
+
+func TestVerifyKeyPair(t *testing.T) {
+	pubKey, privKey := generateTestKey(t)
+	_, otherPriv := generateTestKey(t)
+
+	if err := VerifyKeyPair(pubKey, privKey, ""); err != nil {
+		t.Errorf("matching key pair: %v", err)
+	}
+	if err := VerifyKeyPair(pubKey, otherPriv, ""); err == nil {
+		t.Error("expected mismatched private key to fail")
+	}
+	if err := VerifyKeyPair("", privKey, ""); err == nil {
+		t.Error("expected missing public key to fail")
+	}
+}
+
+func TestDecryptBody(t *testing.T) {
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatalf("generate test key: %v", err)
+	}
+	var pubBuf strings.Builder
+	pubWriter, _ := armor.Encode(&pubBuf, "PGP PUBLIC KEY BLOCK", nil)
+	entity.Serialize(pubWriter) //nolint:errcheck
+	pubWriter.Close()
+
+	if err := entity.EncryptPrivateKeys([]byte("correct horse"), nil); err != nil {
+		t.Fatalf("protect key: %v", err)
+	}
+	var privBuf strings.Builder
+	privWriter, _ := armor.Encode(&privBuf, "PGP PRIVATE KEY BLOCK", nil)
+	entity.SerializePrivateWithoutSigning(privWriter, nil) //nolint:errcheck
+	privWriter.Close()
+
+	msg, err := encryptBody(pubBuf.String(), "Sensitive info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecryptBody(privBuf.String(), "correct horse", msg)
+	if err != nil || got != "Sensitive info" {
+		t.Errorf("DecryptBody = %q, %v", got, err)
+	}
+	if _, err := DecryptBody(privBuf.String(), "", msg); err == nil || !strings.Contains(err.Error(), "passphrase-protected") {
+		t.Errorf("missing passphrase: err = %v", err)
+	}
+	if _, err := DecryptBody(privBuf.String(), "wrong", msg); err == nil {
+		t.Error("expected wrong passphrase to fail")
+	}
+}
+
 func TestCanEncryptValidKey(t *testing.T) {
 	pubKey, _ := generateTestKey(t)
 	m := New(&Config{PGPPublicKey: pubKey})
@@ -201,3 +336,278 @@ func TestCanEncryptAfterReconfigure(t *testing.T) {
 		t.Errorf("expected nil after valid key reconfigured, got: %v", err)
 	}
 }
+
+// fakeSMTPServer is a minimal SMTP server for exercising the dial/STARTTLS
+// path. When tlsConfig is nil the server does not advertise STARTTLS.
+func fakeSMTPServer(t *testing.T, tlsConfig *tls.Config) (host string, port int) {
+	t.Helper()
+	return fakeSMTPServerReplying(t, tlsConfig, "250 queued")
+}
+
+// fakeSMTPServerReplying is fakeSMTPServer answering the end of DATA with
+// dataReply.
+func fakeSMTPServerReplying(t *testing.T, tlsConfig *tls.Config, dataReply string) (host string, port int) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSMTP(conn, tlsConfig, dataReply)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port
+}
+
+func serveFakeSMTP(conn net.Conn, tlsConfig *tls.Config, dataReply string) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	reply := func(s string) {
+		rw.WriteString(s + "\r\n") //nolint:errcheck
+		rw.Flush()                 //nolint:errcheck
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			if tlsConfig != nil {
+				reply("250-localhost\r\n250 STARTTLS")
+			} else {
+				reply("250 localhost")
+			}
+		case cmd == "STARTTLS" && tlsConfig != nil:
+			reply("220 ready")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		case cmd == "DATA":
+			reply("354 go ahead")
+			for {
+				l, err := rw.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+			}
+			reply(dataReply)
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestPingRequiresSTARTTLSByDefault(t *testing.T) {
+	host, port := fakeSMTPServer(t, nil)
+	m := New(&Config{Host: host, Port: port})
+
+	err := m.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected STARTTLS error, got: %v", err)
+	}
+	if stage := ErrorStage(err); stage != StageTLS {
+		t.Errorf("expected stage %q, got %q", StageTLS, stage)
+	}
+}
+
+func TestPingCancelledBySilentRelay(t *testing.T) {
+	// Accept the connection but never send the greeting.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = New(&Config{Host: "127.0.0.1", Port: port}).Ping(ctx)
+	if err == nil {
+		t.Fatal("expected an error from a relay that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Ping returned after %s; cancellation did not abort it", elapsed)
+	}
+}
+
+func TestPingConnectStage(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close() // nothing listening on port any more
+
+	err = New(&Config{Host: "127.0.0.1", Port: port}).Ping(context.Background())
+
+	var pe *PingError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PingError, got: %v", err)
+	}
+	if pe.Stage != StageConnect || !pe.Retryable() {
+		t.Errorf("expected retryable connect failure, got stage %q retryable %v", pe.Stage, pe.Retryable())
+	}
+}
+
+func TestSendAllowInsecureSMTP(t *testing.T) {
+	host, port := fakeSMTPServer(t, nil)
+	m := New(&Config{
+		Host:              host,
+		Port:              port,
+		FromAddress:       "noreply@example.org",
+		AllowInsecureSMTP: true,
+	})
+
+	if err := m.Ping(context.Background()); err != nil {
+		t.Fatalf("ping with insecure SMTP allowed: %v", err)
+	}
+	if err := m.send(context.Background(), Message{To: []string{"admin@example.org"}, Subject: "hi", Body: "body"}); err != nil {
+		t.Fatalf("send with insecure SMTP allowed: %v", err)
+	}
+}
+
+func TestSendReportsRejectionAfterData(t *testing.T) {
+	cases := []struct {
+		reply         string
+		wantTransient bool
+	}{
+		{"451 4.3.0 try again later", true},
+		{"554 5.7.1 message rejected as spam", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.reply, func(t *testing.T) {
+			host, port := fakeSMTPServerReplying(t, nil, tc.reply)
+			m := New(&Config{Host: host, Port: port, FromAddress: "noreply@example.org", AllowInsecureSMTP: true})
+
+			err := m.send(context.Background(), Message{To: []string{"admin@example.org"}, Subject: "hi", Body: "body"})
+			if err == nil {
+				t.Fatal("send succeeded although the relay rejected the message")
+			}
+			if got := errors.Is(err, ErrTransient); got != tc.wantTransient {
+				t.Errorf("transient = %v, want %v (err: %v)", got, tc.wantTransient, err)
+			}
+		})
+	}
+}
+
+// selfSignedCert returns a TLS server config for 127.0.0.1 and the PEM of its
+// self-signed certificate.
+func selfSignedCert(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "firewatch-test-relay"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, string(certPEM)
+}
+
+func TestPingSelfSignedRejectedWithoutCA(t *testing.T) {
+	serverTLS, _ := selfSignedCert(t)
+	host, port := fakeSMTPServer(t, serverTLS)
+	m := New(&Config{Host: host, Port: port})
+
+	if err := m.Ping(context.Background()); err == nil {
+		t.Fatal("expected certificate verification error with system roots")
+	}
+}
+
+func TestPingCustomCA(t *testing.T) {
+	serverTLS, caPEM := selfSignedCert(t)
+	host, port := fakeSMTPServer(t, serverTLS)
+	m := New(&Config{Host: host, Port: port, CACertPEM: caPEM, FromAddress: "noreply@example.org"})
+
+	if err := m.Ping(context.Background()); err != nil {
+		t.Fatalf("ping with custom CA: %v", err)
+	}
+	if err := m.send(context.Background(), Message{To: []string{"admin@example.org"}, Subject: "hi", Body: "body"}); err != nil {
+		t.Fatalf("send with custom CA: %v", err)
+	}
+}
+
+func TestPingInvalidCAPEM(t *testing.T) {
+	serverTLS, _ := selfSignedCert(t)
+	host, port := fakeSMTPServer(t, serverTLS)
+	m := New(&Config{Host: host, Port: port, CACertPEM: "not a certificate"})
+
+	err := m.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid SMTP CA certificate") {
+		t.Fatalf("expected invalid CA error, got: %v", err)
+	}
+}
+
+func TestSendErrorClassification(t *testing.T) {
+	if err := New(&Config{}).SendReport("body"); !errors.Is(err, ErrPGPNotConfigured) {
+		t.Errorf("SendReport without a key: got %v, want ErrPGPNotConfigured", err)
+	}
+	if err := New(&Config{}).CanEncrypt(); !errors.Is(err, ErrPGPNotConfigured) {
+		t.Errorf("CanEncrypt without a key: got %v, want ErrPGPNotConfigured", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	m := New(&Config{Host: "127.0.0.1", Port: port, FromAddress: "fw@example.org"})
+	err = m.send(context.Background(), Message{To: []string{"admin@example.org"}, Subject: "hi", Body: "body"})
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("refused connection: got %v, want ErrTransient", err)
+	}
+
+	err = m.send(context.Background(), Message{To: []string{"not an address"}, Subject: "hi", Body: "body"})
+	if err == nil || errors.Is(err, ErrTransient) {
+		t.Errorf("invalid recipient: got %v, want a permanent error", err)
+	}
+}
@@ -16,14 +16,20 @@ func RenderTemplate(tmpl string, submission map[string]string) string {
 	return result
 }
 
+// sensitivePreview stands in for a sensitive field in previews.
+const sensitivePreview = "[sensitive]"
+
 // RenderPreview substitutes tokens with placeholder values for display purposes.
-// It uses the English locale for field labels and placeholders.
+// It uses the English locale for field labels and placeholders. Sensitive
+// fields are masked rather than shown with their placeholder.
 func RenderPreview(tmpl string, fields []model.Field) string {
 	result := tmpl
 	for _, f := range fields {
 		locale := f.Locale(model.LangEN)
 		sample := locale.Placeholder
-		if sample == "" {
+		if f.Sensitive {
+			sample = sensitivePreview
+		} else if sample == "" {
 			sample = "[" + locale.Label + "]"
 		}
 		result = strings.ReplaceAll(result, "{{"+f.ID+"}}", sample)
@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/firewatch/internal/model"
+)
+
+func TestRenderPreviewMasksSensitiveFields(t *testing.T) {
+	fields := []model.Field{
+		{ID: "where", I18n: map[string]model.FieldLocale{model.LangEN: {Label: "Where", Placeholder: "Main St"}}},
+		{ID: "who", Sensitive: true, I18n: map[string]model.FieldLocale{model.LangEN: {Label: "Who", Placeholder: "Jane Doe"}}},
+	}
+	got := RenderPreview("{{where}} / {{who}}", fields)
+	if want := "Main St / [sensitive]"; got != want {
+		t.Errorf("RenderPreview = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/firewatch/internal/model"
+)
+
+// Verify pings the SMTP relay and checks the recipient key described by s,
+// recording the outcome in s's verification fields. It does not persist s.
+func Verify(ctx context.Context, s *model.AppSettings) {
+	tmp := New(NewConfigFromSettings(s))
+	now := time.Now()
+
+	if err := tmp.Ping(ctx); err != nil {
+		s.SetSMTPResult(err.Error(), string(ErrorStage(err)), now)
+	} else {
+		s.SetSMTPResult("", "", now)
+	}
+
+	if err := tmp.CanEncrypt(); err != nil {
+		s.SetPGPResult(err.Error(), now)
+	} else {
+		s.SetPGPResult("", now)
+	}
+}
+
+// FromDomainWarning returns a warning when s's From address is at a different
+// domain than the SMTP login, a common cause of reports landing in spam
+// because the relay isn't authorised to send for that domain. Logins that
+// aren't email addresses (API keys, plain user names) are not checked, and a
+// subdomain of either side counts as a match. It returns "" when there's
+// nothing to warn about.
+func FromDomainWarning(s *model.AppSettings) string {
+	from, user := emailDomain(s.SMTPFromAddress), emailDomain(s.SMTPUser)
+	if from == "" || user == "" || from == user ||
+		strings.HasSuffix(from, "."+user) || strings.HasSuffix(user, "."+from) {
+		return ""
+	}
+	return "The From address is at " + from + " but the SMTP login is at " + user + "; mail may be marked as spam unless the relay is allowed to send for " + from + "."
+}
+
+// emailDomain returns the lower-cased domain of addr, or "" if addr doesn't
+// look like an email address.
+func emailDomain(addr string) string {
+	i := strings.LastIndexByte(addr, '@')
+	if i < 1 || i == len(addr)-1 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(addr[i+1:]))
+}
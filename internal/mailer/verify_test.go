@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/firewatch/internal/model"
+)
+
+func TestFromDomainWarning(t *testing.T) {
+	tests := []struct {
+		name, from, user string
+		warn             bool
+	}{
+		{"same domain", "reports@example.org", "alerts@example.org", false},
+		{"case differs", "reports@Example.ORG", "alerts@example.org", false},
+		{"from is subdomain", "reports@mail.example.org", "alerts@example.org", false},
+		{"login is subdomain", "reports@example.org", "alerts@smtp.example.org", false},
+		{"different domain", "reports@example.org", "alerts@gmail.com", true},
+		{"lookalike suffix", "reports@badexample.org", "alerts@example.org", true},
+		{"login not an email", "reports@example.org", "AKIAEXAMPLEKEY", false},
+		{"no from address", "", "alerts@example.org", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromDomainWarning(&model.AppSettings{SMTPFromAddress: tt.from, SMTPUser: tt.user})
+			if (got != "") != tt.warn {
+				t.Errorf("FromDomainWarning(%q, %q) = %q, want warning %v", tt.from, tt.user, got, tt.warn)
+			}
+		})
+	}
+}
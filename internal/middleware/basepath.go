@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const contextKeyBasePath contextKey = "basePath"
+
+// URL returns path (which must start with "/") under the base path the
+// request was served from, e.g. "/firewatch/admin/login". Outside BasePath,
+// or with an empty base path, it returns path unchanged.
+func URL(ctx context.Context, path string) string {
+	base, _ := ctx.Value(contextKeyBasePath).(string)
+	return base + path
+}
+
+// CookiePath is the Path for cookies that should cover the whole app.
+func CookiePath(ctx context.Context) string {
+	return URL(ctx, "/")
+}
+
+// BasePath serves next under prefix (e.g. "/firewatch"), for running behind a
+// reverse proxy that forwards a subpath without rewriting it. The prefix is
+// stripped before routing, so routes and path checks are written as if the
+// app were at "/", and recorded for URL. Requests outside prefix get a 404.
+// An empty prefix serves next as is.
+func BasePath(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if prefix == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+			if !ok || (rest != "" && rest[0] != '/') {
+				http.NotFound(w, r)
+				return
+			}
+			if rest == "" {
+				http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+				return
+			}
+
+			r2 := r.WithContext(context.WithValue(r.Context(), contextKeyBasePath, prefix))
+			u := *r.URL
+			u.Path = rest
+			u.RawPath = ""
+			r2.URL = &u
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	var gotPath, gotURL string
+	h := BasePath("/firewatch")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotURL = URL(r.Context(), "/admin/login")
+	}))
+
+	cases := []struct {
+		path     string
+		status   int
+		wantPath string
+	}{
+		{"/firewatch/admin/report", http.StatusOK, "/admin/report"},
+		{"/firewatch/", http.StatusOK, "/"},
+		{"/firewatch", http.StatusMovedPermanently, ""},
+		{"/firewatchx/admin", http.StatusNotFound, ""},
+		{"/admin/report", http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		gotPath, gotURL = "", ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.status || gotPath != tc.wantPath {
+			t.Errorf("%s: status %d path %q, want %d %q", tc.path, rec.Code, gotPath, tc.status, tc.wantPath)
+		}
+		if tc.status == http.StatusOK && gotURL != "/firewatch/admin/login" {
+			t.Errorf("%s: URL = %q, want /firewatch/admin/login", tc.path, gotURL)
+		}
+	}
+
+	// Without a base path, requests and URLs are left alone.
+	h = BasePath("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotURL = r.URL.Path, URL(r.Context(), "/admin/login")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/report", nil))
+	if gotPath != "/admin/report" || gotURL != "/admin/login" {
+		t.Errorf("empty base path: path %q URL %q", gotPath, gotURL)
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// CORS lets pages served from origins (e.g. "https://mirror.example.org")
+// call the wrapped routes from a browser. "*" allows any origin. Credentials
+// are never allowed, so cookies are not sent with cross-origin requests and
+// the admin session can't be used through them. Preflight requests are
+// answered here: 204 for an allowed origin, 403 otherwise. Requests without an
+// Origin header, or from an origin not listed, pass through unchanged and the
+// browser enforces the same-origin policy. An empty list disables CORS.
+func CORS(origins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		anyOrigin := slices.Contains(origins, "*")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			allowed := origin != "" && (anyOrigin || slices.Contains(origins, origin))
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !allowed {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", "GET, POST")
+				h.Set("Access-Control-Allow-Headers", "Accept, Content-Type, Idempotency-Key, If-None-Match")
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Expose-Headers", "ETag")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	cases := []struct {
+		name        string
+		origins     []string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{"disabled", nil, http.MethodPost, "https://mirror.example.org", false, http.StatusAccepted, ""},
+		{"same origin", []string{"https://mirror.example.org"}, http.MethodPost, "", false, http.StatusAccepted, ""},
+		{"allowed", []string{"https://mirror.example.org"}, http.MethodPost, "https://mirror.example.org", false, http.StatusAccepted, "https://mirror.example.org"},
+		{"not listed", []string{"https://mirror.example.org"}, http.MethodPost, "https://evil.example", false, http.StatusAccepted, ""},
+		{"preflight allowed", []string{"https://mirror.example.org"}, http.MethodOptions, "https://mirror.example.org", true, http.StatusNoContent, "https://mirror.example.org"},
+		{"preflight refused", []string{"https://mirror.example.org"}, http.MethodOptions, "https://evil.example", true, http.StatusForbidden, ""},
+		{"wildcard", []string{"*"}, http.MethodGet, "http://abc.onion", false, http.StatusAccepted, "*"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/report", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "content-type")
+			}
+			rec := httptest.NewRecorder()
+			CORS(tc.origins)(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status %d, want %d", rec.Code, tc.status)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tc.allowOrigin)
+			}
+			if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+				t.Error("credentials must never be allowed")
+			}
+			if tc.preflight && tc.status == http.StatusNoContent && rec.Header().Get("Access-Control-Allow-Methods") == "" {
+				t.Error("preflight response lacks Access-Control-Allow-Methods")
+			}
+		})
+	}
+}
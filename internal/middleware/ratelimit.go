@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"net"
 	"net/http"
 	"strings"
@@ -14,24 +18,52 @@ type ipLimiter struct {
 	limiters map[string]*rate.Limiter
 	rate     rate.Limit
 	burst    int
+	salt     []byte
 }
 
 func newIPLimiter(r rate.Limit, burst int) *ipLimiter {
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
 	return &ipLimiter{
 		limiters: make(map[string]*rate.Limiter),
 		rate:     r,
 		burst:    burst,
+		salt:     salt,
 	}
 }
 
+// bucketKey maps a client IP to the key its limiter is stored under, so full
+// addresses are never kept in memory. The IP is truncated to its /24 (IPv4)
+// or /64 (IPv6) network and HMACed with a salt that is random per process and
+// never persisted.
+//
+// The tradeoff: clients sharing a network (a household, office, or carrier NAT)
+// share one bucket and can throttle each other. That is accepted in exchange
+// for not retaining anything that identifies an individual submitter.
+func (ipl *ipLimiter) bucketKey(ip string) string {
+	network := ip
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			network = v4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = parsed.Mask(net.CIDRMask(64, 128)).String()
+		}
+	}
+	mac := hmac.New(sha256.New, ipl.salt)
+	mac.Write([]byte(network))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
 func (ipl *ipLimiter) get(ip string) *rate.Limiter {
+	key := ipl.bucketKey(ip)
+
 	ipl.mu.Lock()
 	defer ipl.mu.Unlock()
 
-	l, ok := ipl.limiters[ip]
+	l, ok := ipl.limiters[key]
 	if !ok {
 		l = rate.NewLimiter(ipl.rate, ipl.burst)
-		ipl.limiters[ip] = l
+		ipl.limiters[key] = l
 	}
 	return l
 }
@@ -39,46 +71,75 @@ func (ipl *ipLimiter) get(ip string) *rate.Limiter {
 // clientIP returns the IP address to use for rate limiting.
 //
 // The raw TCP connection address (r.RemoteAddr) is always used as the default.
-// Forwarded headers (X-Real-IP, X-Forwarded-For) are only trusted when
-// trustedProxy is non-nil and the connecting address falls within that CIDR.
-// This prevents clients from spoofing their IP to bypass rate limiting.
-func clientIP(r *http.Request, trustedProxy *net.IPNet) string {
+// Forwarded headers are only looked at when the connecting address is in one
+// of trustedProxies, which prevents clients from spoofing their IP to bypass
+// rate limiting. With trustUnixPeer, a connection over a Unix domain socket
+// counts as coming from a trusted proxy too: it has no address to check, and
+// the socket's permissions already limit it to the local reverse proxy.
+// X-Forwarded-For is read right to left, skipping trusted proxies, because
+// everything left of the last untrusted hop was written by the client.
+// X-Real-IP is used only when X-Forwarded-For is absent.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet, trustUnixPeer bool) string {
 	connHost, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		// r.RemoteAddr has no port (shouldn't happen with net/http, but be safe)
 		connHost = r.RemoteAddr
 	}
 
-	if trustedProxy != nil {
-		connIP := net.ParseIP(connHost)
-		if connIP != nil && trustedProxy.Contains(connIP) {
-			// Connection is from the trusted proxy — trust forwarded headers.
-			if xri := r.Header.Get("X-Real-IP"); xri != "" {
-				if ip := net.ParseIP(strings.TrimSpace(xri)); ip != nil {
-					return ip.String()
-				}
+	if !isTrustedProxy(net.ParseIP(connHost), trustedProxies) && !(trustUnixPeer && overUnixSocket(r)) {
+		return connHost
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// A malformed hop can't be attributed; stop at the proxy.
+				break
 			}
-			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-				// X-Forwarded-For may be a comma-separated list; leftmost is the client.
-				first, _, _ := strings.Cut(xff, ",")
-				if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
-					return ip.String()
-				}
+			if !isTrustedProxy(ip, trustedProxies) {
+				return ip.String()
 			}
 		}
+		return connHost
+	}
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		if ip := net.ParseIP(strings.TrimSpace(xri)); ip != nil {
+			return ip.String()
+		}
 	}
-
 	return connHost
 }
 
-// RateLimit returns middleware that limits requests per client IP.
-// trustedProxy may be nil; when non-nil, forwarded IP headers are trusted only
-// from connections originating within that CIDR.
-func RateLimit(r rate.Limit, burst int, trustedProxy *net.IPNet) func(http.Handler) http.Handler {
+// overUnixSocket reports whether r arrived on a Unix domain socket listener.
+func overUnixSocket(r *http.Request) bool {
+	_, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr)
+	return ok
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit returns middleware that limits requests per client network; see
+// bucketKey for how addresses are anonymised before use.
+// Forwarded IP headers are trusted only from connections originating within
+// trustedProxies, or over a Unix domain socket when trustUnixPeer is set;
+// otherwise the connecting address is always used.
+func RateLimit(r rate.Limit, burst int, trustedProxies []*net.IPNet, trustUnixPeer bool) func(http.Handler) http.Handler {
 	il := newIPLimiter(r, burst)
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			ip := clientIP(req, trustedProxy)
+			ip := clientIP(req, trustedProxies, trustUnixPeer)
 			if !il.get(ip).Allow() {
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
@@ -87,3 +148,19 @@ func RateLimit(r rate.Limit, burst int, trustedProxy *net.IPNet) func(http.Handl
 		})
 	}
 }
+
+// GlobalRateLimit returns middleware with a single limiter shared by every
+// client. Nothing about the caller is looked at or kept, at the cost of one
+// noisy client being able to slow everyone down.
+func GlobalRateLimit(r rate.Limit, burst int) func(http.Handler) http.Handler {
+	l := rate.NewLimiter(r, burst)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !l.Allow() {
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			h.ServeHTTP(w, req)
+		})
+	}
+}
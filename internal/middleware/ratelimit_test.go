@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitSharesBucketWithinSubnet(t *testing.T) {
+	mw := RateLimit(rate.Every(time.Minute), 1, nil, false)
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("192.0.2.10:1234"); code != http.StatusOK {
+		t.Fatalf("first request: %d", code)
+	}
+	if code := do("192.0.2.99:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("same /24: got %d, want 429", code)
+	}
+	if code := do("198.51.100.10:1234"); code != http.StatusOK {
+		t.Errorf("different /24: got %d, want 200", code)
+	}
+
+	if code := do("[2001:db8:1:2::1]:1234"); code != http.StatusOK {
+		t.Fatalf("first IPv6 request: %d", code)
+	}
+	if code := do("[2001:db8:1:2:ffff::2]:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("same /64: got %d, want 429", code)
+	}
+	if code := do("[2001:db8:1:3::1]:1234"); code != http.StatusOK {
+		t.Errorf("different /64: got %d, want 200", code)
+	}
+}
+
+func TestBucketKeyDoesNotContainIP(t *testing.T) {
+	il := newIPLimiter(1, 1)
+	key := il.bucketKey("192.0.2.10")
+	if key == "192.0.2.10" || key == "192.0.2.0" {
+		t.Errorf("bucket key leaks address: %q", key)
+	}
+	if other := newIPLimiter(1, 1).bucketKey("192.0.2.10"); other == key {
+		t.Error("bucket keys should differ between limiters with different salts")
+	}
+}
+
+func TestGlobalRateLimitIgnoresClient(t *testing.T) {
+	h := GlobalRateLimit(rate.Every(time.Minute), 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, remote := range []string{"192.0.2.1:1", "198.51.100.1:1"} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Errorf("request %d from %s: got %d, want %d", i, remote, rec.Code, want)
+		}
+	}
+}
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	var proxies []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "2001:db8:ffff::/48"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		proxies = append(proxies, n)
+	}
+
+	cases := []struct {
+		name    string
+		remote  string
+		proxies []*net.IPNet
+		xff     []string
+		xri     string
+		want    string
+	}{
+		{"no proxies configured", "192.0.2.1:1", nil, []string{"198.51.100.7"}, "", "192.0.2.1"},
+		{"untrusted peer", "192.0.2.1:1", proxies, []string{"198.51.100.7"}, "198.51.100.8", "192.0.2.1"},
+		{"trusted peer", "10.1.2.3:1", proxies, []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"second trusted range", "[2001:db8:ffff::1]:1", proxies, []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"spoofed leftmost hop", "10.1.2.3:1", proxies, []string{"203.0.113.9, 198.51.100.7"}, "", "198.51.100.7"},
+		{"chained proxies", "10.1.2.3:1", proxies, []string{"198.51.100.7, 10.9.9.9"}, "", "198.51.100.7"},
+		{"repeated header", "10.1.2.3:1", proxies, []string{"203.0.113.9", "198.51.100.7"}, "", "198.51.100.7"},
+		{"malformed hop", "10.1.2.3:1", proxies, []string{"198.51.100.7, junk"}, "", "10.1.2.3"},
+		{"only proxies", "10.1.2.3:1", proxies, []string{"10.9.9.9"}, "", "10.1.2.3"},
+		{"x-real-ip fallback", "10.1.2.3:1", proxies, nil, "198.51.100.8", "198.51.100.8"},
+		{"xff wins over x-real-ip", "10.1.2.3:1", proxies, []string{"198.51.100.7"}, "203.0.113.9", "198.51.100.7"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			for _, v := range tc.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.xri != "" {
+				req.Header.Set("X-Real-IP", tc.xri)
+			}
+			if got := clientIP(req, tc.proxies, false); got != tc.want {
+				t.Errorf("clientIP = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientIPUnixSocketPeer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/fw.sock", Net: "unix"}))
+	req.RemoteAddr = "@"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	if got := clientIP(req, nil, true); got != "198.51.100.7" {
+		t.Errorf("trusted socket peer: clientIP = %s, want the forwarded address", got)
+	}
+	if got := clientIP(req, nil, false); got != "@" {
+		t.Errorf("untrusted socket peer: clientIP = %s, want the peer address", got)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const contextKeyRequestID contextKey = "requestID"
+
+// RequestIDFromContext returns the ID assigned by RequestID, or "" outside
+// an ID-tagged request.
+func RequestIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(contextKeyRequestID).(string)
+	return v
+}
+
+// RequestID tags each request with a random ID so its log lines can be
+// correlated, and echoes it in the X-Request-ID response header. IDs are
+// random rather than sequential and any client-supplied ID is ignored, so
+// they say nothing about other requests. Only admin routes use it; public
+// report submissions are never tagged.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		id := hex.EncodeToString(b)
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), contextKeyRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -2,15 +2,24 @@ package middleware
 
 import "net/http"
 
-// SecurityHeaders sets recommended security headers on every response.
-func SecurityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h := w.Header()
-		h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
-		h.Set("X-Content-Type-Options", "nosniff")
-		h.Set("X-Frame-Options", "DENY")
-		h.Set("Referrer-Policy", "no-referrer")
-		h.Set("Permissions-Policy", "geolocation=(), camera=(), microphone=()")
-		next.ServeHTTP(w, r)
-	})
+// SecurityHeaders sets recommended security headers on every response. In
+// onion mode HSTS is left out, since the site is served over http://*.onion
+// and the header means nothing there, and browsers are told not to prefetch
+// DNS, so nothing on the page can trigger a lookup outside Tor.
+func SecurityHeaders(onion bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			if onion {
+				h.Set("X-DNS-Prefetch-Control", "off")
+			} else {
+				h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+			}
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("Permissions-Policy", "geolocation=(), camera=(), microphone=()")
+			next.ServeHTTP(w, r)
+		})
+	}
 }
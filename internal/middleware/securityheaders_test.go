@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeadersOnionMode(t *testing.T) {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	SecurityHeaders(false)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS missing outside onion mode")
+	}
+
+	rec = httptest.NewRecorder()
+	SecurityHeaders(true)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS sent in onion mode: %q", got)
+	}
+	if rec.Header().Get("X-DNS-Prefetch-Control") != "off" {
+		t.Error("DNS prefetching not disabled in onion mode")
+	}
+	if rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Error("onion mode dropped the other security headers")
+	}
+}
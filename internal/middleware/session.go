@@ -19,6 +19,7 @@ const (
 	contextKeyUserID            contextKey = "userID"
 	contextKeyRole              contextKey = "role"
 	contextKeyMustChangePwd     contextKey = "mustChangePassword"
+	contextKeySessionID         contextKey = "sessionID"
 )
 
 // SessionReader retrieves the user ID for a session token.
@@ -39,64 +40,71 @@ func SignCookie(key []byte, sessionID string) string {
 	return sessionID + "." + hex.EncodeToString(mac.Sum(nil))
 }
 
-// verifyAndExtract validates the signed cookie value and returns the bare
-// session ID. Returns ("", false) if the signature is missing or invalid.
-func verifyAndExtract(key []byte, cookieValue string) (string, bool) {
+// verifyAndExtract validates the signed cookie value against each of keys and
+// returns the bare session ID. Returns ("", false) if the signature is missing
+// or matches none of them.
+func verifyAndExtract(keys [][]byte, cookieValue string) (string, bool) {
 	dot := strings.LastIndex(cookieValue, ".")
 	if dot < 0 {
 		return "", false
 	}
 	sessionID := cookieValue[:dot]
-	sig := cookieValue[dot+1:]
 
-	mac := hmac.New(sha256.New, key)
-	mac.Write([]byte(sessionID))
-	expected := hex.EncodeToString(mac.Sum(nil))
-
-	if !hmac.Equal([]byte(sig), []byte(expected)) {
-		return "", false
+	for _, key := range keys {
+		if hmac.Equal([]byte(cookieValue), []byte(SignCookie(key, sessionID))) {
+			return sessionID, true
+		}
 	}
-	return sessionID, true
+	return "", false
 }
 
 // Session middleware validates the session cookie and populates the request
 // context with the user ID and role. Unauthenticated requests are redirected
-// to /admin/login.
-func Session(key []byte, sessions SessionReader, users userByIDer) func(http.Handler) http.Handler {
+// to /admin/login. Cookies signed with any of keys are accepted, so cookies
+// issued before a secret rotation keep working while the previous key is
+// configured.
+func Session(keys [][]byte, sessions SessionReader, users userByIDer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie(SessionCookieName)
 			if err != nil {
-				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				http.Redirect(w, r, URL(r.Context(), "/admin/login"), http.StatusSeeOther)
 				return
 			}
 
-			sessionID, ok := verifyAndExtract(key, cookie.Value)
+			sessionID, ok := verifyAndExtract(keys, cookie.Value)
 			if !ok {
-				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				http.Redirect(w, r, URL(r.Context(), "/admin/login"), http.StatusSeeOther)
 				return
 			}
 
 			userID, err := sessions.GetUserID(r.Context(), sessionID)
 			if err != nil {
-				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				http.Redirect(w, r, URL(r.Context(), "/admin/login"), http.StatusSeeOther)
 				return
 			}
 
 			user, err := users.GetByID(r.Context(), userID)
 			if err != nil {
-				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				http.Redirect(w, r, URL(r.Context(), "/admin/login"), http.StatusSeeOther)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
 			ctx = context.WithValue(ctx, contextKeyRole, user.Role)
 			ctx = context.WithValue(ctx, contextKeyMustChangePwd, user.MustChangePassword)
+			ctx = context.WithValue(ctx, contextKeySessionID, sessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// SessionIDFromContext returns the current session's ID from the context.
+func SessionIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(contextKeySessionID).(string)
+	return v
+}
+
 // UserIDFromContext returns the authenticated user's ID from the context.
 func UserIDFromContext(ctx context.Context) string {
 	v, _ := ctx.Value(contextKeyUserID).(string)
@@ -131,7 +139,7 @@ func ForcePasswordChange(next http.Handler) http.Handler {
 				"/api/admin/logout":
 				// Allow through
 			default:
-				http.Redirect(w, r, "/admin/change-password", http.StatusSeeOther)
+				http.Redirect(w, r, URL(r.Context(), "/admin/change-password"), http.StatusSeeOther)
 				return
 			}
 		}
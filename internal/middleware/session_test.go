@@ -0,0 +1,23 @@
+package middleware
+
+import "testing"
+
+func TestVerifyAndExtractRotation(t *testing.T) {
+	current := []byte("current key, 32 bytes long......")
+	previous := []byte("previous key, 32 bytes long.....")
+	old := SignCookie(previous, "sess-1")
+
+	if _, ok := verifyAndExtract([][]byte{current}, old); ok {
+		t.Error("cookie signed with a retired key was accepted")
+	}
+	id, ok := verifyAndExtract([][]byte{current, previous}, old)
+	if !ok || id != "sess-1" {
+		t.Errorf("verifyAndExtract = %q, %v; want sess-1, true", id, ok)
+	}
+	if _, ok := verifyAndExtract([][]byte{current, previous}, old+"0"); ok {
+		t.Error("tampered cookie was accepted")
+	}
+	if _, ok := verifyAndExtract([][]byte{current, previous}, "no-signature"); ok {
+		t.Error("unsigned cookie was accepted")
+	}
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -9,6 +13,14 @@ const (
 	LangES = "es"
 )
 
+// CurrentSchemaVersion is the layout of ReportSchema. Older stored schemas
+// are upgraded when they are loaded.
+const CurrentSchemaVersion = 2
+
+// DefaultFieldMaxLength is the maximum number of characters accepted for a
+// field that doesn't set its own MaxLength.
+const DefaultFieldMaxLength = 10000
+
 type LangInfo struct {
 	Code string `json:"Code"`
 	Name string `json:"Name"`
@@ -27,6 +39,16 @@ type ReportSchema struct {
 	Page           PageMeta          `json:"page"`
 	Fields         []Field           `json:"fields"`
 	EmailTemplates map[string]string `json:"emailTemplates"`
+
+	// TruncateOverlong truncates values longer than a field's maximum length
+	// instead of rejecting the submission.
+	TruncateOverlong bool `json:"truncateOverlong,omitempty"`
+
+	// CustomLanguages are languages added by an admin beyond
+	// SupportedLanguages, identified by a BCP 47 code such as "vi" or "tl".
+	// Like the built-in ones they are offered on the form once listed in
+	// Languages.
+	CustomLanguages []LangInfo `json:"customLanguages,omitempty"`
 }
 
 type PageMeta struct {
@@ -37,16 +59,24 @@ type PageLocale struct {
 	Title             string `json:"title"`
 	Subtitle          string `json:"subtitle"`
 	SubmitButtonLabel string `json:"submitButtonLabel"`
+
+	// SuccessMessage replaces the default thank-you text shown after a
+	// report is submitted. SafetyInfo is an optional plain-text block shown
+	// under it, e.g. hotline numbers.
+	SuccessMessage string `json:"successMessage,omitempty"`
+	SafetyInfo     string `json:"safetyInfo,omitempty"`
 }
 
 type Field struct {
-	ID       string                 `json:"id"`
-	Type     string                 `json:"type"` // text, textarea, accordion
-	Order    int                    `json:"order"`
-	Required bool                   `json:"required"`
-	Prefix   string                 `json:"prefix,omitempty"` // optional accented letter shown before the field label
-	Options  []string               `json:"options,omitempty"`
-	I18n     map[string]FieldLocale `json:"i18n"`
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"` // text, textarea, accordion
+	Order     int                    `json:"order"`
+	Required  bool                   `json:"required"`
+	Prefix    string                 `json:"prefix,omitempty"` // optional accented letter shown before the field label
+	Options   []string               `json:"options,omitempty"`
+	MaxLength int                    `json:"maxLength,omitempty"` // 0 = DefaultFieldMaxLength
+	Sensitive bool                   `json:"sensitive,omitempty"` // kept out of logs, field stats and previews; still emailed
+	I18n      map[string]FieldLocale `json:"i18n"`
 }
 
 type FieldLocale struct {
@@ -65,6 +95,152 @@ func (s *ReportSchema) DefaultLang() string {
 	return LangEN
 }
 
+// AvailableLanguages returns the languages that can be enabled on s: the
+// built-in SupportedLanguages followed by s's custom ones.
+func (s *ReportSchema) AvailableLanguages() []LangInfo {
+	return slices.Concat(SupportedLanguages, s.CustomLanguages)
+}
+
+// ResolveLang returns requested if the schema has it enabled, otherwise the
+// default language (the first enabled one, or English if none are).
+func (s *ReportSchema) ResolveLang(requested string) string {
+	if slices.Contains(s.Languages, requested) {
+		return requested
+	}
+	return s.DefaultLang()
+}
+
+// HasField reports whether the schema defines a field with the given ID.
+func (s *ReportSchema) HasField(id string) bool {
+	for _, f := range s.Fields {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldTypes are the field types the public form knows how to render.
+var fieldTypes = []string{"text", "textarea", "select", "accordion"}
+
+// langTagPattern accepts the common shape of a BCP 47 tag: a 2-3 letter
+// language, then optional script, region or variant subtags.
+var langTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Validate reports the first problem that would stop the schema from being
+// rendered or submitted, or nil if it can be published.
+func (s *ReportSchema) Validate() error {
+	if len(s.Languages) == 0 {
+		return fmt.Errorf("schema has no languages")
+	}
+	codes := make(map[string]bool)
+	for _, l := range SupportedLanguages {
+		codes[l.Code] = true
+	}
+	for _, l := range s.CustomLanguages {
+		switch {
+		case !langTagPattern.MatchString(l.Code):
+			return fmt.Errorf("custom language %q is not a valid language code", l.Code)
+		case l.Name == "":
+			return fmt.Errorf("custom language %q has no name", l.Code)
+		case codes[l.Code]:
+			return fmt.Errorf("language %q is defined twice", l.Code)
+		}
+		codes[l.Code] = true
+	}
+	for _, lang := range s.Languages {
+		if !codes[lang] {
+			return fmt.Errorf("unsupported language %q", lang)
+		}
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("schema has no fields")
+	}
+	defaultLang := s.DefaultLang()
+	seen := make(map[string]bool, len(s.Fields))
+	for _, f := range s.Fields {
+		switch {
+		case f.ID == "":
+			return fmt.Errorf("field with empty id")
+		case f.I18n[defaultLang].Label == "":
+			return fmt.Errorf("field %q has no label in the default language %q", f.ID, defaultLang)
+		case seen[f.ID]:
+			return fmt.Errorf("duplicate field id %q", f.ID)
+		case !slices.Contains(fieldTypes, f.Type):
+			return fmt.Errorf("field %q has unknown type %q", f.ID, f.Type)
+		case f.Type == "select" && len(f.Options) == 0:
+			return fmt.Errorf("select field %q has no options", f.ID)
+		case f.MaxLength < 0:
+			return fmt.Errorf("field %q has a negative maxLength", f.ID)
+		case f.Required && f.Type == "accordion":
+			return fmt.Errorf("field %q is a section with no input, so it can't be required", f.ID)
+		}
+		if err := f.validateOptions(); err != nil {
+			return err
+		}
+		seen[f.ID] = true
+	}
+	// Reports are always rendered with the English template.
+	if s.EmailTemplates[LangEN] == "" {
+		return fmt.Errorf("schema has no English email template")
+	}
+	return nil
+}
+
+// Warnings lists problems that don't stop the schema from being published
+// but probably aren't intended: fields sharing a display order, overall or
+// in a language that overrides the order. Such fields keep their position in
+// Fields relative to each other.
+func (s *ReportSchema) Warnings() []string {
+	var warnings []string
+	warnings = append(warnings, duplicateOrders(s.Fields, "", func(f Field) int { return f.Order })...)
+	for _, lang := range s.Languages {
+		overridden := slices.ContainsFunc(s.Fields, func(f Field) bool { return f.I18n[lang].Order != 0 })
+		if !overridden {
+			continue // same as the overall order, already checked
+		}
+		warnings = append(warnings, duplicateOrders(s.Fields, lang, func(f Field) int { return f.DisplayOrder(lang) })...)
+	}
+	return warnings
+}
+
+func duplicateOrders(fields []Field, lang string, order func(Field) int) []string {
+	var warnings []string
+	first := make(map[int]string, len(fields))
+	for _, f := range fields {
+		o := order(f)
+		prev, dup := first[o]
+		if !dup {
+			first[o] = f.ID
+			continue
+		}
+		msg := fmt.Sprintf("fields %q and %q share display order %d", prev, f.ID, o)
+		if lang != "" {
+			msg += fmt.Sprintf(" in %q", lang)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
+
+// validateOptions catches select options a reporter could pick but never
+// submit: an empty one reads as no answer, and one longer than the field's
+// maximum length is rejected or cut short.
+func (f Field) validateOptions() error {
+	if f.Type != "select" {
+		return nil
+	}
+	for _, o := range f.Options {
+		switch {
+		case o == "":
+			return fmt.Errorf("select field %q has an empty option", f.ID)
+		case utf8.RuneCountInString(o) > f.MaxLen():
+			return fmt.Errorf("select field %q has option %q longer than its maxLength of %d", f.ID, o, f.MaxLen())
+		}
+	}
+	return nil
+}
+
 // Locale returns the PageLocale for lang, falling back to English.
 func (pm PageMeta) Locale(lang string) PageLocale {
 	if l, ok := pm.I18n[lang]; ok {
@@ -76,15 +252,25 @@ func (pm PageMeta) Locale(lang string) PageLocale {
 	return PageLocale{}
 }
 
-// Locale returns the FieldLocale for lang, falling back to English.
+// Locale returns the FieldLocale for lang, falling back to English. If that
+// leaves no label, the field ID is used so the form is never unlabeled.
 func (f Field) Locale(lang string) FieldLocale {
-	if l, ok := f.I18n[lang]; ok {
-		return l
+	l, ok := f.I18n[lang]
+	if !ok {
+		l = f.I18n[LangEN]
 	}
-	if l, ok := f.I18n[LangEN]; ok {
-		return l
+	if l.Label == "" {
+		l.Label = f.ID
+	}
+	return l
+}
+
+// MaxLen returns the maximum number of characters accepted for the field.
+func (f Field) MaxLen() int {
+	if f.MaxLength > 0 {
+		return f.MaxLength
 	}
-	return FieldLocale{}
+	return DefaultFieldMaxLength
 }
 
 // DisplayOrder returns the per-language display order, falling back to Field.Order.
@@ -98,7 +284,7 @@ func (f Field) DisplayOrder(lang string) int {
 // DefaultSALUTESchema returns the initial SALUTE report schema (v2).
 func DefaultSALUTESchema() ReportSchema {
 	return ReportSchema{
-		SchemaVersion: 2,
+		SchemaVersion: CurrentSchemaVersion,
 		UpdatedAt:     time.Now().UTC(),
 		Languages:     []string{LangEN},
 		Page: PageMeta{
@@ -158,9 +344,16 @@ func DefaultSALUTESchema() ReportSchema {
 					LangES: {Label: "Equipo", Description: "Describa cualquier equipo, vehículos o herramientas observadas.", Placeholder: "Dos vehículos sin identificación...", Prefix: "E", Order: 6},
 				},
 			},
+			{
+				ID: "additional_info", Type: "textarea", Order: 7, Required: false,
+				I18n: map[string]FieldLocale{
+					LangEN: {Label: "Additional Information", Description: "Anything else that might be useful. Do not include details that could identify you.", Placeholder: "Other observations...", Order: 7},
+					LangES: {Label: "Información Adicional", Description: "Cualquier otro detalle que pueda ser útil. No incluya datos que puedan identificarle.", Placeholder: "Otras observaciones...", Order: 7},
+				},
+			},
 		},
 		EmailTemplates: map[string]string{
-			LangEN: "New Community Report\n\nSize:\n{{size}}\n\nActivity:\n{{activity}}\n\nLocation:\n{{location}}\n\nUniform:\n{{uniform}}\n\nTime:\n{{time}}\n\nEquipment:\n{{equipment}}\n\n---\nThis report was submitted anonymously.",
+			LangEN: "New Community Report\n\nSize:\n{{size}}\n\nActivity:\n{{activity}}\n\nLocation:\n{{location}}\n\nUniform:\n{{uniform}}\n\nTime:\n{{time}}\n\nEquipment:\n{{equipment}}\n\nAdditional Information:\n{{additional_info}}\n\n---\nThis report was submitted anonymously.",
 		},
 	}
 }
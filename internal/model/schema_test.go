@@ -0,0 +1,137 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveLang(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages []string
+		requested string
+		want      string
+	}{
+		{"requested and enabled", []string{LangEN, LangES}, LangES, LangES},
+		{"requested but disabled", []string{LangEN}, LangES, LangEN},
+		{"unknown falls back to default", []string{LangES, LangEN}, "xx", LangES},
+		{"empty falls back to default", []string{LangES}, "", LangES},
+		{"no languages falls back to English", nil, LangES, LangEN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ReportSchema{Languages: tt.languages}
+			if got := s.ResolveLang(tt.requested); got != tt.want {
+				t.Errorf("ResolveLang(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCustomLanguages(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  []LangInfo
+		enabled []string
+		wantErr bool
+	}{
+		{"built-in only", nil, []string{LangEN, LangES}, false},
+		{"custom enabled", []LangInfo{{"vi", "Tiếng Việt"}}, []string{LangEN, "vi"}, false},
+		{"region subtag", []LangInfo{{"pt-BR", "Português"}}, []string{LangEN, "pt-BR"}, false},
+		{"custom declared but off", []LangInfo{{"tl", "Tagalog"}}, []string{LangEN}, false},
+		{"undeclared", nil, []string{LangEN, "vi"}, true},
+		{"bad code", []LangInfo{{"vietnamese!", "Tiếng Việt"}}, []string{LangEN}, true},
+		{"no name", []LangInfo{{"vi", ""}}, []string{LangEN}, true},
+		{"shadows built-in", []LangInfo{{LangES, "Spanish"}}, []string{LangEN}, true},
+		{"duplicate custom", []LangInfo{{"vi", "Tiếng Việt"}, {"vi", "Vietnamese"}}, []string{LangEN}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := DefaultSALUTESchema()
+			s.CustomLanguages = tt.custom
+			s.Languages = tt.enabled
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFieldWithoutDefaultLocale(t *testing.T) {
+	s := DefaultSALUTESchema()
+	s.Fields = append(s.Fields, Field{
+		ID:   "field_99",
+		Type: "text",
+		I18n: map[string]FieldLocale{LangES: {Label: "Notas"}},
+	})
+
+	if err := s.Validate(); err == nil {
+		t.Error("Validate accepted a field with no English label")
+	}
+
+	f := s.Fields[len(s.Fields)-1]
+	if got := f.Locale(LangES).Label; got != "Notas" {
+		t.Errorf("Locale(es).Label = %q, want Notas", got)
+	}
+	if got := f.Locale(LangEN).Label; got != "field_99" {
+		t.Errorf("Locale(en).Label = %q, want the field ID", got)
+	}
+}
+
+func TestValidateRejectsUnsubmittableFields(t *testing.T) {
+	label := map[string]FieldLocale{LangEN: {Label: "Extra"}}
+	tests := []struct {
+		name  string
+		field Field
+	}{
+		{"required section", Field{ID: "f_section", Type: "accordion", Required: true, I18n: label}},
+		{"empty select option", Field{ID: "f_empty", Type: "select", Options: []string{"Yes", ""}, I18n: label}},
+		{"option over maxLength", Field{ID: "f_long", Type: "select", Options: []string{"Yes", "Not sure"}, MaxLength: 3, I18n: label}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := DefaultSALUTESchema()
+			s.Fields = append(s.Fields, tt.field)
+			err := s.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.field.ID) {
+				t.Errorf("Validate() = %v, want an error naming %q", err, tt.field.ID)
+			}
+		})
+	}
+
+	s := DefaultSALUTESchema()
+	s.Fields = append(s.Fields,
+		Field{ID: "f_section", Type: "accordion", I18n: label},
+		Field{ID: "f_choice", Type: "select", Required: true, Options: []string{"Yes", "No"}, MaxLength: 3, I18n: label},
+	)
+	if err := s.Validate(); err != nil {
+		t.Errorf("Validate() rejected a consistent schema: %v", err)
+	}
+}
+
+func TestWarningsFlagDuplicateOrders(t *testing.T) {
+	s := DefaultSALUTESchema()
+	s.Languages = []string{LangEN, LangES}
+	if w := s.Warnings(); len(w) != 0 {
+		t.Fatalf("default schema has warnings: %v", w)
+	}
+
+	s.Fields[1].Order = s.Fields[0].Order
+	w := s.Warnings()
+	if len(w) != 1 || !strings.Contains(w[0], s.Fields[0].ID) || !strings.Contains(w[0], s.Fields[1].ID) || strings.Contains(w[0], " in ") {
+		t.Errorf("overall duplicate: warnings = %v", w)
+	}
+
+	s = DefaultSALUTESchema()
+	s.Languages = []string{LangEN, LangES}
+	loc := s.Fields[2].I18n[LangES]
+	loc.Order = s.Fields[3].DisplayOrder(LangES)
+	s.Fields[2].I18n[LangES] = loc
+	w = s.Warnings()
+	if len(w) != 1 || !strings.Contains(w[0], `in "es"`) {
+		t.Errorf("per-language duplicate: warnings = %v", w)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("duplicate orders must not fail Validate: %v", err)
+	}
+}
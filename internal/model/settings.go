@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type AppSettings struct {
 	DestinationEmail      string `json:"destinationEmail"`
 	EmailSubjectTemplate  string `json:"emailSubjectTemplate"`
@@ -9,13 +11,104 @@ type AppSettings struct {
 	SMTPPass              string `json:"smtpPass"`
 	SMTPFromAddress       string `json:"smtpFromAddress"`
 	SMTPFromName          string `json:"smtpFromName"`
+	SMTPAllowInsecure     bool   `json:"smtpAllowInsecure"`
+	SMTPCACert            string `json:"smtpCACert"`
+	SMTPSkipVerify        bool   `json:"smtpSkipVerify"`
 	ReportRetentionPolicy string `json:"reportRetentionPolicy"`
 	MaintenanceMode       bool   `json:"maintenanceMode"`
 	PGPKey                string `json:"pgpKey"`
 
-	// Verification state — set automatically on save and at startup.
-	SMTPVerified bool   `json:"smtpVerified"`
-	SMTPError    string `json:"smtpError"`
-	PGPVerified  bool   `json:"pgpVerified"`
-	PGPError     string `json:"pgpError"`
+	// PGPUseWKD looks up DestinationEmail's key by Web Key Directory on save
+	// and at startup. PGPWKDKey holds the last key found that way; it is used
+	// instead of PGPKey while set, and PGPKey remains the fallback.
+	PGPUseWKD bool   `json:"pgpUseWkd"`
+	PGPWKDKey string `json:"pgpWkdKey,omitempty"`
+
+	// MailSendInterval is the pause in seconds between queued email sends.
+	// Zero means the default of one second.
+	MailSendInterval int `json:"mailSendInterval,omitempty"`
+
+	// DigestInterval, in minutes, batches reports into a single email. Zero
+	// (the default) sends each report as it arrives. DigestMaxBatch sends a
+	// digest early once that many reports are waiting; zero means no cap.
+	DigestInterval int `json:"digestInterval,omitempty"`
+	DigestMaxBatch int `json:"digestMaxBatch,omitempty"`
+
+	// KeepTrackingParams forwards links verbatim. By default utm_*, fbclid,
+	// gclid etc. are stripped from URLs in submitted field values before the
+	// report is emailed. It is an opt-out so that settings saved before the
+	// option existed, which have no such key, get scrubbing too.
+	KeepTrackingParams bool `json:"keepTrackingParams,omitempty"`
+
+	// ForwardDuplicateReports forwards every copy of a report. By default an
+	// identical report submitted again within a couple of minutes
+	// (double-clicks, client retries) is dropped; only a salted hash is held,
+	// in memory. Like KeepTrackingParams it is an opt-out, so settings saved
+	// before the option existed get deduplication too.
+	ForwardDuplicateReports bool `json:"forwardDuplicateReports,omitempty"`
+
+	// IncludeReceivedTime adds the time the server received a report, to the
+	// minute in UTC, to the metadata above the forwarded report. Off by
+	// default: see Decision 4 in docs/design.md.
+	IncludeReceivedTime bool `json:"includeReceivedTime"`
+
+	// Verification state — set automatically on save and at startup. The
+	// ErrorAt times are when the current error was first seen, and are zero
+	// while verified.
+	SMTPVerified  bool      `json:"smtpVerified"`
+	SMTPError     string    `json:"smtpError"`
+	SMTPErrorCode string    `json:"smtpErrorCode"` // connect, tls or auth — see mailer.PingStage
+	SMTPErrorAt   time.Time `json:"smtpErrorAt"`
+	PGPVerified   bool      `json:"pgpVerified"`
+	PGPError      string    `json:"pgpError"`
+	PGPErrorAt    time.Time `json:"pgpErrorAt"`
+}
+
+// SetSMTPResult records the outcome of an SMTP check at now; msg is empty on
+// success. SMTPErrorAt is kept while the same error repeats, so periodic
+// re-checks show how long it has been failing rather than when it last ran.
+func (s *AppSettings) SetSMTPResult(msg, code string, now time.Time) {
+	s.SMTPErrorAt = errorSince(s.SMTPError, msg, s.SMTPErrorAt, now)
+	s.SMTPVerified = msg == ""
+	s.SMTPError = msg
+	s.SMTPErrorCode = code
+}
+
+// SetPGPResult records the outcome of a PGP key check, like SetSMTPResult.
+func (s *AppSettings) SetPGPResult(msg string, now time.Time) {
+	s.PGPErrorAt = errorSince(s.PGPError, msg, s.PGPErrorAt, now)
+	s.PGPVerified = msg == ""
+	s.PGPError = msg
+}
+
+func errorSince(prev, msg string, since, now time.Time) time.Time {
+	switch {
+	case msg == "":
+		return time.Time{}
+	case msg == prev && !since.IsZero():
+		return since
+	}
+	return now.UTC()
+}
+
+// RecipientKey returns the public key reports are encrypted to.
+func (s *AppSettings) RecipientKey() string {
+	if s.PGPUseWKD && s.PGPWKDKey != "" {
+		return s.PGPWKDKey
+	}
+	return s.PGPKey
+}
+
+// UnavailableReason explains why the public report form is currently disabled,
+// or returns "" if it is available.
+func (s *AppSettings) UnavailableReason() string {
+	switch {
+	case !s.SMTPVerified:
+		return "SMTP verification failed"
+	case !s.PGPVerified:
+		return "PGP verification failed"
+	case s.MaintenanceMode:
+		return "maintenance mode is enabled"
+	}
+	return ""
 }
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetSMTPResultKeepsFirstSeen(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var s AppSettings
+
+	s.SetSMTPResult("auth failed", "auth", t0)
+	if s.SMTPVerified || !s.SMTPErrorAt.Equal(t0) {
+		t.Fatalf("first failure: verified=%v at=%v", s.SMTPVerified, s.SMTPErrorAt)
+	}
+
+	s.SetSMTPResult("auth failed", "auth", t0.Add(time.Hour))
+	if !s.SMTPErrorAt.Equal(t0) {
+		t.Errorf("repeated error moved SMTPErrorAt to %v", s.SMTPErrorAt)
+	}
+
+	s.SetSMTPResult("dial: connection refused", "connect", t0.Add(2*time.Hour))
+	if !s.SMTPErrorAt.Equal(t0.Add(2 * time.Hour)) {
+		t.Errorf("new error kept SMTPErrorAt at %v", s.SMTPErrorAt)
+	}
+
+	s.SetSMTPResult("", "", t0.Add(3*time.Hour))
+	if !s.SMTPVerified || s.SMTPError != "" || s.SMTPErrorCode != "" || !s.SMTPErrorAt.IsZero() {
+		t.Errorf("success left %+v", s)
+	}
+}
+
+func TestSetPGPResult(t *testing.T) {
+	t0 := time.Now()
+	var s AppSettings
+	s.SetPGPResult("no PGP public key configured", t0)
+	if s.PGPVerified || s.PGPErrorAt.Location() != time.UTC || !s.PGPErrorAt.Equal(t0) {
+		t.Fatalf("failure: verified=%v at=%v", s.PGPVerified, s.PGPErrorAt)
+	}
+	s.SetPGPResult("", t0)
+	if !s.PGPVerified || !s.PGPErrorAt.IsZero() {
+		t.Errorf("success: verified=%v at=%v", s.PGPVerified, s.PGPErrorAt)
+	}
+}
@@ -16,6 +16,9 @@ const (
 	StatusInactive Status = "inactive"
 )
 
+// InviteTTL is how long an admin invitation link stays valid.
+const InviteTTL = 48 * time.Hour
+
 type AdminUser struct {
 	ID                 string     `json:"id"`
 	Username           string     `json:"username"`
@@ -26,7 +26,7 @@ func (s *DeliveryStore) Record(ctx context.Context, kind, status string) {
 	_, err := s.db.ExecContext(ctx,
 		`INSERT INTO delivery_log (kind, status) VALUES (?, ?)`, kind, status)
 	if err != nil {
-		slog.Error("delivery_log: failed to record", "kind", kind, "status", status, "err", err)
+		slog.ErrorContext(ctx, "delivery_log: failed to record", "kind", kind, "status", status, "err", err)
 	}
 }
 
@@ -69,3 +69,23 @@ func (s *DeliveryStore) Stats24h(ctx context.Context) (*DeliveryStats, error) {
 	}
 	return &out, rows.Err()
 }
+
+// LastEmail returns when an email was last delivered and last permanently
+// failed, or zero times if there is no such entry.
+func (s *DeliveryStore) LastEmail(ctx context.Context) (ok, failed time.Time, err error) {
+	var okAt, failedAt sql.NullString
+	err = s.db.QueryRowContext(ctx,
+		`SELECT MAX(CASE WHEN status = 'ok' THEN created_at END),
+		        MAX(CASE WHEN status = 'error' THEN created_at END)
+		 FROM delivery_log WHERE kind = 'email'`).Scan(&okAt, &failedAt)
+	if err != nil {
+		return ok, failed, fmt.Errorf("last email delivery: %w", err)
+	}
+	if okAt.Valid {
+		ok, _ = time.Parse("2006-01-02 15:04:05", okAt.String)
+	}
+	if failedAt.Valid {
+		failed, _ = time.Parse("2006-01-02 15:04:05", failedAt.String)
+	}
+	return ok, failed, nil
+}
@@ -4,24 +4,97 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	dbpkg "github.com/firewatch/internal/db"
 	"github.com/firewatch/internal/model"
 )
 
+// ErrDraftConflict is returned by UpdateDraft when the draft was saved by
+// someone else after the caller loaded it.
+var ErrDraftConflict = errors.New("draft schema was modified since it was loaded")
+
+// ErrInvalidSchema is returned by PromoteDraft and Rollback when the schema
+// to publish doesn't pass validation; the wrapped error says why.
+var ErrInvalidSchema = errors.New("invalid schema")
+
 type SchemaStore struct {
 	q  *dbpkg.Queries
 	db *sql.DB
+
+	// The parsed live schema and the row it came from. A new publish always
+	// creates a new live row, so comparing IDs is enough to invalidate it,
+	// including after a publish by another process.
+	liveMu sync.Mutex
+	liveID int64
+	live   *model.ReportSchema
 }
 
 func NewSchemaStore(db *sql.DB) *SchemaStore {
 	return &SchemaStore{q: dbpkg.New(db), db: db}
 }
 
-// LiveSchema returns the currently published schema.
+// LiveSchema returns the currently published schema. The result is shared
+// between callers and must not be modified.
 func (s *SchemaStore) LiveSchema(ctx context.Context) (*model.ReportSchema, error) {
-	return s.load(ctx, true)
+	live, _, err := s.LiveWithRevision(ctx)
+	return live, err
+}
+
+// LiveWithRevision returns the published schema together with its revision.
+// Both come from the same row, so a publish between two separate calls to
+// LiveRevision and LiveSchema can't pair one revision with another's schema.
+// The schema is shared between callers and must not be modified.
+func (s *SchemaStore) LiveWithRevision(ctx context.Context) (*model.ReportSchema, int64, error) {
+	id, err := s.q.GetLiveRevision(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.liveMu.Lock()
+	if s.live != nil && s.liveID == id {
+		live := s.live
+		s.liveMu.Unlock()
+		return live, id, nil
+	}
+	s.liveMu.Unlock()
+
+	// A publish since GetLiveRevision is fine: the row read here carries its
+	// own ID, which is what gets cached and returned.
+	row, err := s.q.GetLiveSchema(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	live, err := decodeSchema(row.Schema)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.liveMu.Lock()
+	s.liveID, s.live = row.ID, live
+	s.liveMu.Unlock()
+	return live, row.ID, nil
+}
+
+// LiveRevision identifies the published schema; it changes on every publish
+// or rollback.
+func (s *SchemaStore) LiveRevision(ctx context.Context) (int64, error) {
+	return s.q.GetLiveRevision(ctx)
+}
+
+// LiveStamp returns the live schema's revision and when it was published,
+// without loading the schema itself.
+func (s *SchemaStore) LiveStamp(ctx context.Context) (revision int64, publishedAt time.Time, err error) {
+	row, err := s.q.GetLiveSchemaStamp(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	publishedAt, err = time.Parse(time.DateTime, row.UpdatedAt)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("parse live schema updated_at: %w", err)
+	}
+	return row.ID, publishedAt.UTC(), nil
 }
 
 // DraftSchema returns the current draft schema.
@@ -34,28 +107,52 @@ func (s *SchemaStore) load(ctx context.Context, live bool) (*model.ReportSchema,
 	if err != nil {
 		return nil, err
 	}
-	var schema model.ReportSchema
-	if err := json.Unmarshal(raw, &schema); err != nil {
-		return nil, err
-	}
-	return &schema, nil
+	return decodeSchema(raw)
+}
+
+// DraftRevision identifies the current draft. Every save creates a new draft
+// row, so the row ID changes whenever the draft does.
+func (s *SchemaStore) DraftRevision(ctx context.Context) (int64, error) {
+	return s.q.GetDraftRevision(ctx)
 }
 
 // SaveDraft persists the draft schema.
 func (s *SchemaStore) SaveDraft(ctx context.Context, schema *model.ReportSchema, updatedBy string) error {
+	_, err := s.saveDraft(ctx, schema, updatedBy, nil)
+	return err
+}
+
+// UpdateDraft persists the draft schema only if the draft is still at
+// revision, returning ErrDraftConflict otherwise. It returns the new revision.
+func (s *SchemaStore) UpdateDraft(ctx context.Context, schema *model.ReportSchema, updatedBy string, revision int64) (int64, error) {
+	return s.saveDraft(ctx, schema, updatedBy, &revision)
+}
+
+// saveDraft replaces the draft, checking it is still at *expected when that
+// is non-nil. The check and the write share a transaction.
+func (s *SchemaStore) saveDraft(ctx context.Context, schema *model.ReportSchema, updatedBy string, expected *int64) (int64, error) {
 	raw, err := json.Marshal(schema)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return 0, fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 	q := s.q.WithTx(tx)
+	if expected != nil {
+		current, err := q.GetDraftRevision(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("get draft revision: %w", err)
+		}
+		if current != *expected {
+			return 0, ErrDraftConflict
+		}
+	}
 	if err := q.DeleteDraftSchemas(ctx); err != nil {
-		return fmt.Errorf("delete drafts: %w", err)
+		return 0, fmt.Errorf("delete drafts: %w", err)
 	}
 
 	err = q.InsertDraftSchema(ctx, dbpkg.InsertDraftSchemaParams{
@@ -64,9 +161,13 @@ func (s *SchemaStore) SaveDraft(ctx context.Context, schema *model.ReportSchema,
 		UpdatedBy:  sql.NullString{String: updatedBy, Valid: updatedBy != ""},
 	})
 	if err != nil {
-		return fmt.Errorf("insert draft: %w", err)
+		return 0, fmt.Errorf("insert draft: %w", err)
 	}
-	return tx.Commit()
+	revision, err := q.GetDraftRevision(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get draft revision: %w", err)
+	}
+	return revision, tx.Commit()
 }
 
 // PromoteDraft atomically sets the latest draft as live, then seeds a new
@@ -80,6 +181,18 @@ func (s *SchemaStore) PromoteDraft(ctx context.Context, updatedBy string) error
 	defer func() { _ = tx.Rollback() }()
 
 	qtx := s.q.WithTx(tx)
+	raw, err := qtx.GetReportSchema(ctx, fastBoolConv(false))
+	if err != nil {
+		return err
+	}
+	draft, err := decodeSchema(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	if err := draft.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
 	if err := qtx.DemoteLiveSchemas(ctx); err != nil {
 		return err
 	}
@@ -100,6 +213,90 @@ func (s *SchemaStore) PromoteDraft(ctx context.Context, updatedBy string) error
 	return s.SaveDraft(ctx, live, updatedBy)
 }
 
+// SchemaRevision is one entry in the publish history.
+type SchemaRevision struct {
+	ID          int64  `json:"id"`
+	Version     int    `json:"version"`
+	Live        bool   `json:"live"`
+	PublishedBy string `json:"publishedBy"`
+	PublishedAt string `json:"publishedAt"`
+}
+
+// History lists every schema that has been published, newest first. The
+// first entry is the live schema. PublishedBy is the publisher's username,
+// or the stored identifier if that account no longer exists.
+func (s *SchemaStore) History(ctx context.Context) ([]SchemaRevision, error) {
+	rows, err := s.q.ListSchemaHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]SchemaRevision, len(rows))
+	for i, row := range rows {
+		history[i] = SchemaRevision{
+			ID:          row.ID,
+			Version:     int(row.Version),
+			Live:        row.IsLive == 1,
+			PublishedBy: row.PublishedBy,
+			PublishedAt: row.UpdatedAt,
+		}
+	}
+	return history, nil
+}
+
+// Rollback re-publishes the retired schema with the given history ID. The
+// current live schema is retired in the same transaction, so it stays in the
+// history and can itself be restored later. Like PromoteDraft, the draft is
+// then reset to the newly live schema. Returns ErrNotFound if id isn't a
+// retired schema and ErrInvalidSchema if it fails validation.
+func (s *SchemaStore) Rollback(ctx context.Context, id int64, updatedBy string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := s.q.WithTx(tx)
+
+	row, err := q.GetRetiredSchema(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get schema %d: %w", id, err)
+	}
+	schema, err := decodeSchema(row.Schema)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	if err := schema.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	// Publish a copy of the old row through the usual draft → live path, so
+	// the restored entry shows up in the history with its new publisher.
+	if err := q.DeleteDraftSchemas(ctx); err != nil {
+		return fmt.Errorf("delete drafts: %w", err)
+	}
+	by := sql.NullString{String: updatedBy, Valid: updatedBy != ""}
+	if err := q.InsertDraftSchema(ctx, dbpkg.InsertDraftSchemaParams{
+		Version:    row.Version,
+		SchemaData: row.Schema,
+		UpdatedBy:  by,
+	}); err != nil {
+		return fmt.Errorf("insert rollback copy: %w", err)
+	}
+	if err := q.DemoteLiveSchemas(ctx); err != nil {
+		return err
+	}
+	if err := q.PromoteLatestDraft(ctx, by); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.SaveDraft(ctx, schema, updatedBy)
+}
+
 // RevertDraftToLive overwrites the current draft with the live schema,
 // effectively discarding any unpublished changes.
 func (s *SchemaStore) RevertDraftToLive(ctx context.Context, updatedBy string) error {
@@ -110,42 +307,45 @@ func (s *SchemaStore) RevertDraftToLive(ctx context.Context, updatedBy string) e
 	return s.SaveDraft(ctx, live, updatedBy)
 }
 
-// SeedDefault inserts the default SALUTE schema as both draft and live if the
-// table is empty.
+// SeedDefault publishes the default SALUTE schema, with a matching draft, if
+// no schema exists yet. The live row is inserted only when the table is
+// empty, in a single statement, so instances starting at the same time can't
+// both seed; whichever loses sees zero rows inserted and leaves the draft alone.
 func (s *SchemaStore) SeedDefault(ctx context.Context) error {
-	count, err := s.q.CountReportSchemas(ctx)
+	schema := model.DefaultSALUTESchema()
+	raw, err := json.Marshal(schema)
 	if err != nil {
 		return err
 	}
-	if count > 0 {
-		return nil
-	}
 
-	schema := model.DefaultSALUTESchema()
-	raw, err := json.Marshal(schema)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("begin tx: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
+	q := s.q.WithTx(tx)
 
-	// Insert draft row.
-	if err := s.q.InsertDraftSchema(ctx, dbpkg.InsertDraftSchemaParams{
+	by := sql.NullString{String: "admin", Valid: true}
+	seeded, err := q.SeedLiveSchema(ctx, dbpkg.SeedLiveSchemaParams{
 		Version:    int64(schema.SchemaVersion),
 		SchemaData: json.RawMessage(raw),
-		UpdatedBy:  sql.NullString{String: "admin", Valid: true},
-	}); err != nil {
-		return err
+		UpdatedBy:  by,
+	})
+	if err != nil {
+		return fmt.Errorf("seed live schema: %w", err)
 	}
-
-	// Insert live row.
-	if err := s.q.PromoteLatestDraft(ctx, sql.NullString{String: "admin", Valid: true}); err != nil {
-		return err
+	if seeded == 0 {
+		return nil
 	}
 
-	return s.q.InsertDraftSchema(ctx, dbpkg.InsertDraftSchemaParams{
+	if err := q.InsertDraftSchema(ctx, dbpkg.InsertDraftSchemaParams{
 		Version:    int64(schema.SchemaVersion),
 		SchemaData: json.RawMessage(raw),
-		UpdatedBy:  sql.NullString{String: "admin", Valid: true},
-	})
+		UpdatedBy:  by,
+	}); err != nil {
+		return fmt.Errorf("seed draft schema: %w", err)
+	}
+	return tx.Commit()
 }
 
 func fastBoolConv(b bool) int64 {
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/firewatch/internal/model"
+)
+
+// Stored schemas are upgraded on read, one version at a time, so rows written
+// by older releases keep loading without a data migration.
+//
+// v1 → v2: v1 was single-language. The page title, subtitle and submit label,
+// and each field's label, description and placeholder, were flat strings, and
+// there was one "emailTemplate". v2 moves all of these under English entries in
+// the i18n maps ("page.i18n.en", "fields[].i18n.en", "emailTemplates.en") and
+// sets "languages" to ["en"]. Field IDs, types, order, required flags and
+// options are unchanged, so tokens in the email template still resolve.
+
+// schemaV1 is the single-language layout from the original design.
+type schemaV1 struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	Page      struct {
+		Title             string `json:"title"`
+		Subtitle          string `json:"subtitle"`
+		SubmitButtonLabel string `json:"submitButtonLabel"`
+	} `json:"page"`
+	Fields []struct {
+		ID          string   `json:"id"`
+		Type        string   `json:"type"`
+		Order       int      `json:"order"`
+		Label       string   `json:"label"`
+		Description string   `json:"description"`
+		Placeholder string   `json:"placeholder"`
+		Required    bool     `json:"required"`
+		Options     []string `json:"options,omitempty"`
+	} `json:"fields"`
+	EmailTemplate string `json:"emailTemplate"`
+}
+
+// decodeSchema unmarshals a stored schema, upgrading it to
+// model.CurrentSchemaVersion first if it is older.
+func decodeSchema(raw []byte) (*model.ReportSchema, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+	if header.SchemaVersion < model.CurrentSchemaVersion {
+		return migrateSchema(raw, header.SchemaVersion)
+	}
+
+	var schema model.ReportSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// migrateSchema upgrades raw from fromVersion to model.CurrentSchemaVersion.
+// A missing version (0) is treated as v1, which predates the field.
+func migrateSchema(raw []byte, fromVersion int) (*model.ReportSchema, error) {
+	switch fromVersion {
+	case 0, 1:
+		return migrateSchemaV1(raw)
+	default:
+		return nil, fmt.Errorf("no migration from schema version %d", fromVersion)
+	}
+}
+
+func migrateSchemaV1(raw []byte) (*model.ReportSchema, error) {
+	var old schemaV1
+	if err := json.Unmarshal(raw, &old); err != nil {
+		return nil, fmt.Errorf("decode v1 schema: %w", err)
+	}
+
+	schema := &model.ReportSchema{
+		SchemaVersion: 2,
+		UpdatedAt:     old.UpdatedAt,
+		UpdatedBy:     old.UpdatedBy,
+		Languages:     []string{model.LangEN},
+		Page: model.PageMeta{I18n: map[string]model.PageLocale{
+			model.LangEN: {
+				Title:             old.Page.Title,
+				Subtitle:          old.Page.Subtitle,
+				SubmitButtonLabel: old.Page.SubmitButtonLabel,
+			},
+		}},
+		Fields:         make([]model.Field, len(old.Fields)),
+		EmailTemplates: map[string]string{model.LangEN: old.EmailTemplate},
+	}
+	for i, f := range old.Fields {
+		schema.Fields[i] = model.Field{
+			ID:       f.ID,
+			Type:     f.Type,
+			Order:    f.Order,
+			Required: f.Required,
+			Options:  f.Options,
+			I18n: map[string]model.FieldLocale{
+				model.LangEN: {Label: f.Label, Description: f.Description, Placeholder: f.Placeholder, Order: f.Order},
+			},
+		}
+	}
+	return schema, nil
+}
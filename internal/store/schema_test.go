@@ -0,0 +1,348 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/firewatch/internal/model"
+)
+
+func TestUpdateDraftRejectsStaleRevision(t *testing.T) {
+	ctx := context.Background()
+	s := NewSchemaStore(newTestDB(t))
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+
+	loaded, err := s.DraftRevision(ctx)
+	if err != nil {
+		t.Fatalf("DraftRevision: %v", err)
+	}
+
+	first := model.DefaultSALUTESchema()
+	first.TruncateOverlong = true
+	next, err := s.UpdateDraft(ctx, &first, "alice", loaded)
+	if err != nil {
+		t.Fatalf("first UpdateDraft: %v", err)
+	}
+	if next == loaded {
+		t.Fatal("revision did not change after a save")
+	}
+
+	// A second admin still holding the old revision must not overwrite.
+	second := model.DefaultSALUTESchema()
+	if _, err := s.UpdateDraft(ctx, &second, "bob", loaded); !errors.Is(err, ErrDraftConflict) {
+		t.Fatalf("stale UpdateDraft: got %v, want ErrDraftConflict", err)
+	}
+	draft, err := s.DraftSchema(ctx)
+	if err != nil {
+		t.Fatalf("DraftSchema: %v", err)
+	}
+	if !draft.TruncateOverlong {
+		t.Error("stale update overwrote the newer draft")
+	}
+
+	if _, err := s.UpdateDraft(ctx, &second, "bob", next); err != nil {
+		t.Errorf("UpdateDraft at current revision: %v", err)
+	}
+}
+
+func TestPromoteDraftRejectsInvalidSchema(t *testing.T) {
+	ctx := context.Background()
+	s := NewSchemaStore(newTestDB(t))
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+	rev, err := s.DraftRevision(ctx)
+	if err != nil {
+		t.Fatalf("DraftRevision: %v", err)
+	}
+
+	bad := model.DefaultSALUTESchema()
+	bad.Fields[0].Type = "accordion" // required, but has no input
+	if _, err := s.UpdateDraft(ctx, &bad, "alice", rev); err != nil {
+		t.Fatalf("UpdateDraft: %v", err)
+	}
+	if err := s.PromoteDraft(ctx, "alice"); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("PromoteDraft: got %v, want ErrInvalidSchema", err)
+	}
+
+	live, err := s.LiveSchema(ctx)
+	if err != nil {
+		t.Fatalf("LiveSchema: %v", err)
+	}
+	if live.Fields[0].Type == "accordion" {
+		t.Error("invalid draft was published")
+	}
+}
+
+func TestHistoryKeepsReplacedSchemas(t *testing.T) {
+	ctx := context.Background()
+	s := NewSchemaStore(newTestDB(t))
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+	for _, by := range []string{"alice", "bob"} {
+		if err := s.PromoteDraft(ctx, by); err != nil {
+			t.Fatalf("PromoteDraft(%s): %v", by, err)
+		}
+	}
+
+	history, err := s.History(ctx)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	var got []string
+	for _, h := range history {
+		got = append(got, h.PublishedBy)
+	}
+	if len(history) != 3 || got[0] != "bob" || got[1] != "alice" || got[2] != "admin" {
+		t.Fatalf("history publishers = %v, want [bob alice admin]", got)
+	}
+	if !history[0].Live || history[1].Live || history[2].Live {
+		t.Errorf("only the newest entry should be live: %+v", history)
+	}
+
+	// The draft and live schema are unaffected by the retained rows.
+	if _, err := s.LiveSchema(ctx); err != nil {
+		t.Errorf("LiveSchema: %v", err)
+	}
+	if _, err := s.DraftSchema(ctx); err != nil {
+		t.Errorf("DraftSchema: %v", err)
+	}
+}
+
+func TestRollbackRepublishesRetiredSchema(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	s := NewSchemaStore(db)
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+
+	changed := model.DefaultSALUTESchema()
+	changed.TruncateOverlong = true
+	if err := s.SaveDraft(ctx, &changed, "alice"); err != nil {
+		t.Fatalf("SaveDraft: %v", err)
+	}
+	if err := s.PromoteDraft(ctx, "alice"); err != nil {
+		t.Fatalf("PromoteDraft: %v", err)
+	}
+
+	history, err := s.History(ctx)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	original := history[len(history)-1]
+	if err := s.Rollback(ctx, original.ID, "bob"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	live, err := s.LiveSchema(ctx)
+	if err != nil {
+		t.Fatalf("LiveSchema: %v", err)
+	}
+	if live.TruncateOverlong {
+		t.Error("live schema is still the rolled-back-from version")
+	}
+	draft, err := s.DraftSchema(ctx)
+	if err != nil {
+		t.Fatalf("DraftSchema: %v", err)
+	}
+	if draft.TruncateOverlong {
+		t.Error("draft was not reset to the restored schema")
+	}
+	if history, _ = s.History(ctx); len(history) != 3 || history[0].PublishedBy != "bob" {
+		t.Errorf("history after rollback = %+v, want 3 entries led by bob", history)
+	}
+
+	// The live schema can't be "rolled back" to, and neither can a retired
+	// schema that no longer validates.
+	if err := s.Rollback(ctx, history[0].ID, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rollback to live schema: got %v, want ErrNotFound", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE report_schema SET schema = ? WHERE id = ?`, []byte(`{"languages":[],"fields":[]}`), history[1].ID); err != nil {
+		t.Fatalf("corrupt retired schema: %v", err)
+	}
+	if err := s.Rollback(ctx, history[1].ID, "bob"); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("rollback to invalid schema: got %v, want ErrInvalidSchema", err)
+	}
+}
+
+// v1SchemaPayload is a single-language schema as written before i18n.
+const v1SchemaPayload = `{
+  "schemaVersion": 1,
+  "updatedAt": "2026-02-19T10:00:00Z",
+  "updatedBy": "admin@example.org",
+  "page": {
+    "title": "Community Incident Report",
+    "subtitle": "All submissions are anonymous. No identifying information is collected.",
+    "submitButtonLabel": "Submit Report"
+  },
+  "fields": [
+    {
+      "id": "field_001",
+      "type": "textarea",
+      "order": 1,
+      "label": "Size",
+      "description": "Describe the number of people or scale of the incident.",
+      "placeholder": "e.g., Approximately 10–15 individuals...",
+      "required": true
+    },
+    {
+      "id": "field_002",
+      "type": "select",
+      "order": 2,
+      "label": "Activity",
+      "options": ["Patrol", "Checkpoint"],
+      "required": false
+    }
+  ],
+  "emailTemplate": "New Community Report\n\nSize:\n{{field_001}}\n\nActivity:\n{{field_002}}"
+}`
+
+func TestMigrateSchemaV1(t *testing.T) {
+	schema, err := migrateSchema([]byte(v1SchemaPayload), 1)
+	if err != nil {
+		t.Fatalf("migrateSchema: %v", err)
+	}
+
+	if schema.SchemaVersion != model.CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", schema.SchemaVersion, model.CurrentSchemaVersion)
+	}
+	if len(schema.Languages) != 1 || schema.Languages[0] != model.LangEN {
+		t.Errorf("Languages = %v, want [en]", schema.Languages)
+	}
+	if got := schema.Page.Locale(model.LangEN).Title; got != "Community Incident Report" {
+		t.Errorf("page title = %q", got)
+	}
+	if len(schema.Fields) != 2 {
+		t.Fatalf("got %d fields, want 2", len(schema.Fields))
+	}
+	size := schema.Fields[0]
+	if size.ID != "field_001" || !size.Required || size.Locale(model.LangEN).Label != "Size" || size.DisplayOrder(model.LangEN) != 1 {
+		t.Errorf("size field = %+v", size)
+	}
+	if activity := schema.Fields[1]; activity.Type != "select" || len(activity.Options) != 2 {
+		t.Errorf("activity field = %+v", activity)
+	}
+	if schema.EmailTemplates[model.LangEN] == "" {
+		t.Error("email template was not carried over")
+	}
+	if err := schema.Validate(); err != nil {
+		t.Errorf("migrated schema does not validate: %v", err)
+	}
+}
+
+func TestLoadUpgradesStoredV1Schema(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if _, err := db.ExecContext(ctx, `INSERT INTO report_schema (version, is_live, schema) VALUES (1, 1, ?)`, []byte(v1SchemaPayload)); err != nil {
+		t.Fatalf("insert v1 row: %v", err)
+	}
+
+	schema, err := NewSchemaStore(db).LiveSchema(ctx)
+	if err != nil {
+		t.Fatalf("LiveSchema: %v", err)
+	}
+	if schema.SchemaVersion != model.CurrentSchemaVersion || schema.Fields[0].Locale(model.LangEN).Label != "Size" {
+		t.Errorf("stored v1 schema was not upgraded: %+v", schema)
+	}
+}
+
+func TestSeedDefaultIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	s := NewSchemaStore(db)
+	for i := 0; i < 2; i++ {
+		if err := s.SeedDefault(ctx); err != nil {
+			t.Fatalf("SeedDefault #%d: %v", i+1, err)
+		}
+	}
+
+	var live, drafts int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FILTER (WHERE is_live = 1), COUNT(*) FILTER (WHERE is_live = 0) FROM report_schema`).Scan(&live, &drafts); err != nil {
+		t.Fatalf("count schemas: %v", err)
+	}
+	if live != 1 || drafts != 1 {
+		t.Errorf("after seeding twice: %d live, %d drafts; want 1 and 1", live, drafts)
+	}
+	if _, err := s.LiveSchema(ctx); err != nil {
+		t.Errorf("LiveSchema: %v", err)
+	}
+	if _, err := s.DraftSchema(ctx); err != nil {
+		t.Errorf("DraftSchema: %v", err)
+	}
+}
+
+func TestLiveSchemaCacheFollowsPublish(t *testing.T) {
+	ctx := context.Background()
+	s := NewSchemaStore(newTestDB(t))
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+
+	first, err := s.LiveSchema(ctx)
+	if err != nil {
+		t.Fatalf("LiveSchema: %v", err)
+	}
+	if again, _ := s.LiveSchema(ctx); again != first {
+		t.Error("unchanged live schema was parsed again instead of served from cache")
+	}
+	rev, _ := s.LiveRevision(ctx)
+
+	changed := model.DefaultSALUTESchema()
+	changed.TruncateOverlong = true
+	if err := s.SaveDraft(ctx, &changed, "alice"); err != nil {
+		t.Fatalf("SaveDraft: %v", err)
+	}
+	if err := s.PromoteDraft(ctx, "alice"); err != nil {
+		t.Fatalf("PromoteDraft: %v", err)
+	}
+
+	live, err := s.LiveSchema(ctx)
+	if err != nil {
+		t.Fatalf("LiveSchema after publish: %v", err)
+	}
+	if !live.TruncateOverlong {
+		t.Error("cache still serves the schema from before the publish")
+	}
+	if next, _ := s.LiveRevision(ctx); next == rev {
+		t.Error("LiveRevision did not change after a publish")
+	}
+	withRev, next, err := s.LiveWithRevision(ctx)
+	if err != nil {
+		t.Fatalf("LiveWithRevision: %v", err)
+	}
+	if withRev != live || next == rev {
+		t.Errorf("LiveWithRevision = %p, %d; want the cached schema %p with a new revision", withRev, next, live)
+	}
+}
+
+func TestLiveStampFollowsPublish(t *testing.T) {
+	ctx := context.Background()
+	s := NewSchemaStore(newTestDB(t))
+	if err := s.SeedDefault(ctx); err != nil {
+		t.Fatalf("SeedDefault: %v", err)
+	}
+
+	rev, publishedAt, err := s.LiveStamp(ctx)
+	if err != nil {
+		t.Fatalf("LiveStamp: %v", err)
+	}
+	if want, _ := s.LiveRevision(ctx); rev != want {
+		t.Errorf("LiveStamp revision = %d, want %d", rev, want)
+	}
+	if time.Since(publishedAt) > time.Minute || publishedAt.Location() != time.UTC {
+		t.Errorf("publishedAt = %v, want a recent UTC time", publishedAt)
+	}
+
+	if err := s.PromoteDraft(ctx, "alice"); err != nil {
+		t.Fatalf("PromoteDraft: %v", err)
+	}
+	if next, _, _ := s.LiveStamp(ctx); next == rev {
+		t.Error("LiveStamp revision did not change after a publish")
+	}
+}
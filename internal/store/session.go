@@ -5,12 +5,22 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
 	dbpkg "github.com/firewatch/internal/db"
 )
 
-const sessionTTL = 4 * time.Hour
+// SessionTTL is how long a session lasts after sign-in or its last Touch.
+const SessionTTL = 4 * time.Hour
+
+// sessionMaxAge caps how long Touch can keep one session alive. Past it the
+// admin signs in again however active they are.
+const sessionMaxAge = 24 * time.Hour
+
+// sessionTimeLayout is how session times are stored: UTC, to the second.
+const sessionTimeLayout = "2006-01-02 15:04:05"
 
 type SessionStore struct {
 	q *dbpkg.Queries
@@ -23,11 +33,11 @@ func NewSessionStore(db *sql.DB) *SessionStore {
 // Create inserts a new session and returns its ID.
 func (s *SessionStore) Create(ctx context.Context, userID string) (string, error) {
 	id := newToken()
-	expiresAt := time.Now().Add(sessionTTL).UTC()
+	expiresAt := time.Now().Add(SessionTTL).UTC()
 	err := s.q.CreateSession(ctx, dbpkg.CreateSessionParams{
 		ID:        id,
 		UserID:    userID,
-		ExpiresAt: expiresAt.UTC().Format("2006-01-02 15:04:05"),
+		ExpiresAt: expiresAt.UTC().Format(sessionTimeLayout),
 	})
 	return id, err
 }
@@ -38,6 +48,52 @@ func (s *SessionStore) GetUserID(ctx context.Context, sessionID string) (string,
 	return s.q.GetSessionUserID(ctx, sessionID)
 }
 
+// ExpiresAt returns when the session expires. It returns ErrNotFound if the
+// session does not exist or has already expired.
+func (s *SessionStore) ExpiresAt(ctx context.Context, sessionID string) (time.Time, error) {
+	row, err := s.q.GetSessionTimes(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(sessionTimeLayout, row.ExpiresAt)
+}
+
+// Touch extends a live session to SessionTTL from now, but never past
+// sessionMaxAge after it was created, and returns the new expiry. It returns
+// ErrNotFound if the session does not exist or has already expired.
+func (s *SessionStore) Touch(ctx context.Context, sessionID string) (time.Time, error) {
+	row, err := s.q.GetSessionTimes(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	created, err := time.Parse(sessionTimeLayout, row.CreatedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse session created_at: %w", err)
+	}
+
+	expiresAt := time.Now().UTC().Add(SessionTTL).Truncate(time.Second)
+	if limit := created.Add(sessionMaxAge); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	n, err := s.q.ExtendSession(ctx, dbpkg.ExtendSessionParams{
+		ExpiresAt: expiresAt.Format(sessionTimeLayout),
+		ID:        sessionID,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n == 0 {
+		return time.Time{}, ErrNotFound
+	}
+	return expiresAt, nil
+}
+
 // DeleteAllByUserID removes all sessions for a user (used on logout / password change).
 func (s *SessionStore) DeleteAllByUserID(ctx context.Context, userID string) error {
 	return s.q.DeleteSessionsByUserID(ctx, userID)
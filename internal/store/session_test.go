@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/firewatch/internal/crypto"
+)
+
+func TestSessionTouch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	key := make([]byte, 32)
+	users := NewUserStore(db, crypto.New(key), key)
+	sessions := NewSessionStore(db)
+
+	if err := users.Create(ctx, "u1", "alice", "alice@example.org", "hash", "admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	id, err := sessions.Create(ctx, "u1")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	expires, err := sessions.ExpiresAt(ctx, id)
+	if err != nil {
+		t.Fatalf("ExpiresAt: %v", err)
+	}
+	if d := time.Until(expires); d < SessionTTL-time.Minute || d > SessionTTL {
+		t.Errorf("new session expires in %v, want about %v", d, SessionTTL)
+	}
+
+	// Shorten the session, then Touch it back to a full TTL.
+	if _, err := db.ExecContext(ctx, `UPDATE sessions SET expires_at = ? WHERE id = ?`,
+		time.Now().UTC().Add(time.Minute).Format(sessionTimeLayout), id); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := sessions.Touch(ctx, id)
+	if err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if got, _ := sessions.ExpiresAt(ctx, id); !got.Equal(touched) || time.Until(touched) < SessionTTL-time.Minute {
+		t.Errorf("Touch = %v, stored %v; want about %v from now", touched, got, SessionTTL)
+	}
+
+	// Touch never extends a session past its maximum age.
+	if _, err := db.ExecContext(ctx, `UPDATE sessions SET created_at = ? WHERE id = ?`,
+		time.Now().UTC().Add(-sessionMaxAge+time.Hour).Format(sessionTimeLayout), id); err != nil {
+		t.Fatal(err)
+	}
+	if touched, err := sessions.Touch(ctx, id); err != nil || time.Until(touched) > time.Hour {
+		t.Errorf("Touch near max age = %v (err %v), want at most an hour from now", touched, err)
+	}
+
+	// Expired and unknown sessions can't be revived.
+	if _, err := db.ExecContext(ctx, `UPDATE sessions SET expires_at = ? WHERE id = ?`,
+		time.Now().UTC().Add(-time.Minute).Format(sessionTimeLayout), id); err != nil {
+		t.Fatal(err)
+	}
+	for _, sid := range []string{id, "unknown"} {
+		if _, err := sessions.Touch(ctx, sid); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Touch(%s): got %v, want ErrNotFound", sid, err)
+		}
+		if _, err := sessions.ExpiresAt(ctx, sid); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ExpiresAt(%s): got %v, want ErrNotFound", sid, err)
+		}
+	}
+}
@@ -5,26 +5,79 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io/fs"
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/firewatch/internal/crypto"
 	dbpkg "github.com/firewatch/internal/db"
+	"github.com/firewatch/internal/mailer"
 	"github.com/firewatch/internal/model"
 )
 
+// settingsAAD binds the settings ciphertext to its purpose.
+var settingsAAD = []byte("settings")
+
 type SettingsStore struct {
+	db      *sql.DB
 	q       *dbpkg.Queries
 	crypter *crypto.Crypter
+
+	// cached holds the decrypted settings so hot paths like the maintenance
+	// middleware don't decrypt on every request. Save replaces it, so every
+	// change made through this store is visible immediately. The mailer
+	// already keeps the same secrets in memory, so this adds no new exposure.
+	mu     sync.RWMutex
+	cached *model.AppSettings
+
+	// saveMu serializes Save so subscribers see changes in the order they
+	// were persisted.
+	saveMu      sync.Mutex
+	subscribers []func(model.AppSettings)
 }
 
 func NewSettingsStore(db *sql.DB, crypter *crypto.Crypter) *SettingsStore {
-	return &SettingsStore{q: dbpkg.New(db), crypter: crypter}
+	return &SettingsStore{db: db, q: dbpkg.New(db), crypter: crypter}
 }
 
-// Load decrypts and returns the current settings. Seeds from env vars if no row exists.
+// Load returns the current settings, decrypting them only on the first call.
+// Seeds from env vars if no row exists, and fills an empty PGP key from a
+// mounted secret file; see pgpKeyFromFile. Each call gets its own copy, which
+// the caller may modify and pass to Save.
 func (s *SettingsStore) Load(ctx context.Context) (*model.AppSettings, error) {
+	s.mu.RLock()
+	cached := s.cached
+	s.mu.RUnlock()
+	if cached != nil {
+		settings := *cached
+		return &settings, nil
+	}
+
+	settings, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fillCache(settings), nil
+}
+
+// fillCache caches settings read by Load unless a Save has cached newer ones
+// since, and returns a copy of whichever is cached. load runs without saveMu,
+// so a Save can commit between its read and this call.
+func (s *SettingsStore) fillCache(settings *model.AppSettings) *model.AppSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached == nil {
+		c := *settings
+		s.cached = &c
+	}
+	current := *s.cached
+	return &current
+}
+
+func (s *SettingsStore) load(ctx context.Context) (*model.AppSettings, error) {
 	data, err := s.q.GetSettings(ctx)
 	if errors.Is(err, sql.ErrNoRows) {
 		defaults := settingsFromEnv()
@@ -36,30 +89,77 @@ func (s *SettingsStore) Load(ctx context.Context) (*model.AppSettings, error) {
 		return nil, err
 	}
 
-	slog.Info("settings: loaded from database")
-	plaintext, err := s.crypter.Decrypt(data)
+	slog.InfoContext(ctx, "settings: loaded from database")
+	plaintext, err := s.crypter.DecryptWithContext(data, settingsAAD)
+	if err != nil {
+		// Rows written before settings were bound to their purpose have no
+		// AAD; they are rewritten with it on the next Save.
+		plaintext, err = s.crypter.Decrypt(data)
+	}
 	if err != nil {
-		slog.Error("settings: decryption failed", "err", err)
+		slog.ErrorContext(ctx, "settings: decryption failed", "err", err)
 		return nil, err
 	}
+	defer crypto.Zero(plaintext)
 	var settings model.AppSettings
 	if err := json.Unmarshal(plaintext, &settings); err != nil {
 		return nil, err
 	}
+	if settings.PGPKey == "" {
+		settings.PGPKey = pgpKeyFromFile()
+	}
 	return &settings, nil
 }
 
-// Save encrypts and persists settings.
+// Subscribe registers fn to be called with the new settings after every
+// successful Save. Callbacks run synchronously, in registration order, and
+// must not call Save themselves.
+func (s *SettingsStore) Subscribe(fn func(model.AppSettings)) {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Save encrypts and persists settings, then notifies subscribers.
 func (s *SettingsStore) Save(ctx context.Context, settings *model.AppSettings) error {
-	raw, err := json.Marshal(settings)
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	ciphertext, err := s.encrypt(settings)
 	if err != nil {
 		return err
 	}
-	ciphertext, err := s.crypter.Encrypt(raw)
-	if err != nil {
+	if err := s.q.UpsertSettings(ctx, ciphertext); err != nil {
 		return err
 	}
-	return s.q.UpsertSettings(ctx, ciphertext)
+	s.saved(settings)
+	return nil
+}
+
+func (s *SettingsStore) encrypt(settings *model.AppSettings) ([]byte, error) {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zero(raw)
+	return s.crypter.EncryptWithContext(raw, settingsAAD)
+}
+
+// saved caches settings once they are persisted and notifies subscribers.
+// The caller holds saveMu.
+func (s *SettingsStore) saved(settings *model.AppSettings) {
+	s.setCached(settings)
+	for _, fn := range s.subscribers {
+		fn(*settings)
+	}
+}
+
+// setCached stores a copy, so later changes to settings by the caller don't
+// leak into the cache without a Save.
+func (s *SettingsStore) setCached(settings *model.AppSettings) {
+	c := *settings
+	s.mu.Lock()
+	s.cached = &c
+	s.mu.Unlock()
 }
 
 func settingsFromEnv() *model.AppSettings {
@@ -67,7 +167,7 @@ func settingsFromEnv() *model.AppSettings {
 	if port == 0 {
 		port = 587
 	}
-	return &model.AppSettings{
+	s := &model.AppSettings{
 		DestinationEmail:      os.Getenv("DESTINATION_EMAIL"),
 		EmailSubjectTemplate:  "New Community Report",
 		SMTPHost:              os.Getenv("SMTP_HOST"),
@@ -80,4 +180,40 @@ func settingsFromEnv() *model.AppSettings {
 		MaintenanceMode:       true,
 		PGPKey:                os.Getenv("PGP_PUBLIC_KEY"),
 	}
+	if s.PGPKey == "" {
+		s.PGPKey = pgpKeyFromFile()
+	}
+	return s
+}
+
+// dockerPGPKeyPath is where Docker and Kubernetes mount a secret named
+// pgp_public_key. It is used when PGP_PUBLIC_KEY_FILE is unset.
+var dockerPGPKeyPath = "/run/secrets/pgp_public_key"
+
+// pgpKeyFromFile returns the armored public key in PGP_PUBLIC_KEY_FILE, or in
+// the Docker secret when that is unset, for deployments that mount the key
+// rather than paste it into the Settings UI. It returns "" when there is no
+// such file, or when the key in it can't be used to encrypt: a bad file key
+// is logged and rejected here rather than seeded into the stored settings.
+func pgpKeyFromFile() string {
+	path := os.Getenv("PGP_PUBLIC_KEY_FILE")
+	explicit := path != ""
+	if !explicit {
+		path = dockerPGPKeyPath
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// A missing Docker secret just means the deployment doesn't use one.
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("settings: could not read PGP key file", "path", path, "err", err)
+		}
+		return ""
+	}
+	key := strings.TrimSpace(string(data))
+	if err := mailer.New(&mailer.Config{PGPPublicKey: key}).CanEncrypt(); err != nil {
+		slog.Error("settings: PGP key file rejected", "path", path, "err", err)
+		return ""
+	}
+	slog.Info("settings: PGP key seeded from file", "path", path)
+	return key
 }
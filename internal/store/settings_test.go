@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+
+	"github.com/firewatch/internal/crypto"
+	"github.com/firewatch/internal/model"
+)
+
+func newTestSettingsStore(t *testing.T) *SettingsStore {
+	t.Helper()
+	return NewSettingsStore(newTestDB(t), crypto.New(make([]byte, 32)))
+}
+
+func TestSettingsCache(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSettingsStore(t)
+
+	first, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Editing a loaded copy without saving must not change what others see.
+	first.MaintenanceMode = !first.MaintenanceMode
+	second, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if second.MaintenanceMode == first.MaintenanceMode {
+		t.Error("unsaved change leaked into the cache")
+	}
+
+	// A saved change is visible on the next Load, and is what's in the
+	// database.
+	if err := s.Save(ctx, first); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got, _ := s.Load(ctx); got.MaintenanceMode != first.MaintenanceMode {
+		t.Error("Load after Save returned stale settings")
+	}
+	fromDB, err := s.load(ctx)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if fromDB.MaintenanceMode != first.MaintenanceMode {
+		t.Error("cache and database disagree after Save")
+	}
+}
+
+func TestSettingsLoadDoesNotOverwriteNewerSave(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSettingsStore(t)
+	if _, err := s.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// A Load that missed the cache reads the database, then a Save commits
+	// and caches newer settings before the Load fills the cache.
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+	stale, err := s.load(ctx)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	newer := *stale
+	newer.SMTPHost = "smtp.example.org"
+	if err := s.Save(ctx, &newer); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if got := s.fillCache(stale); got.SMTPHost != newer.SMTPHost {
+		t.Errorf("Load returned SMTPHost %q, want the saved %q", got.SMTPHost, newer.SMTPHost)
+	}
+	if got, _ := s.Load(ctx); got.SMTPHost != newer.SMTPHost {
+		t.Errorf("cache holds SMTPHost %q after a stale fill, want %q", got.SMTPHost, newer.SMTPHost)
+	}
+}
+
+func TestSettingsSubscribe(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSettingsStore(t)
+
+	var got []string
+	s.Subscribe(func(settings model.AppSettings) {
+		got = append(got, settings.SMTPHost)
+	})
+
+	settings, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got = nil // seeding defaults on first Load also notifies
+
+	settings.SMTPHost = "smtp.example.org"
+	if err := s.Save(ctx, settings); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(got) != 1 || got[0] != "smtp.example.org" {
+		t.Errorf("subscriber saw %q, want one call with the saved host", got)
+	}
+}
+
+func TestSettingsPGPKeyFromFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dockerPGPKeyPath = filepath.Join(dir, "missing")
+	t.Cleanup(func() { dockerPGPKeyPath = "/run/secrets/pgp_public_key" })
+
+	key := strings.TrimSpace(testPublicKey(t))
+	path := filepath.Join(dir, "pgp_public_key")
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGP_PUBLIC_KEY", "")
+	t.Setenv("PGP_PUBLIC_KEY_FILE", path)
+
+	// First run: no settings row yet.
+	s := newTestSettingsStore(t)
+	got, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got.PGPKey != key {
+		t.Errorf("seeded PGPKey = %q, want the file's contents", got.PGPKey)
+	}
+
+	// A stored row with the key cleared is filled from the file again.
+	got.PGPKey = ""
+	if err := s.Save(ctx, got); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	fromDB, err := s.load(ctx)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if fromDB.PGPKey != key {
+		t.Errorf("PGPKey after load = %q, want the file's contents", fromDB.PGPKey)
+	}
+
+	// A pasted key wins over the file.
+	got.PGPKey = "pasted"
+	if err := s.Save(ctx, got); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if fromDB, _ := s.load(ctx); fromDB.PGPKey != "pasted" {
+		t.Errorf("PGPKey = %q, want the stored key", fromDB.PGPKey)
+	}
+}
+
+func TestSettingsPGPKeyFromFileRejected(t *testing.T) {
+	dir := t.TempDir()
+	dockerPGPKeyPath = filepath.Join(dir, "missing")
+	t.Cleanup(func() { dockerPGPKeyPath = "/run/secrets/pgp_public_key" })
+
+	path := filepath.Join(dir, "pgp_public_key")
+	if err := os.WriteFile(path, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n...\n-----END PGP PUBLIC KEY BLOCK-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGP_PUBLIC_KEY_FILE", path)
+
+	if got := pgpKeyFromFile(); got != "" {
+		t.Errorf("pgpKeyFromFile = %q, want an unusable key rejected", got)
+	}
+}
+
+func TestSettingsFromEnvPrefersPGPKeyVariable(t *testing.T) {
+	var logs strings.Builder
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	path := filepath.Join(t.TempDir(), "pgp_public_key")
+	if err := os.WriteFile(path, []byte(testPublicKey(t)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGP_PUBLIC_KEY", "from-env")
+	t.Setenv("PGP_PUBLIC_KEY_FILE", path)
+
+	if got := settingsFromEnv().PGPKey; got != "from-env" {
+		t.Errorf("PGPKey = %q, want the PGP_PUBLIC_KEY value", got)
+	}
+	if strings.Contains(logs.String(), "PGP key") {
+		t.Errorf("key file read although PGP_PUBLIC_KEY is set: %s", logs.String())
+	}
+}
+
+// testPublicKey returns a freshly generated armored public key.
+func testPublicKey(t *testing.T) string {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatalf("generate test key: %v", err)
+	}
+	var buf strings.Builder
+	w, _ := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	entity.Serialize(w) //nolint:errcheck
+	w.Close()
+	return buf.String()
+}
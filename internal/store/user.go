@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/firewatch/internal/auth"
 	"github.com/firewatch/internal/crypto"
 	dbpkg "github.com/firewatch/internal/db"
 	"github.com/firewatch/internal/model"
@@ -16,6 +17,16 @@ import (
 // ErrNotFound is returned when a requested record does not exist.
 var ErrNotFound = errors.New("not found")
 
+// ErrUsernameTaken is returned when creating a user whose username is already in use.
+var ErrUsernameTaken = errors.New("username already taken")
+
+// ErrEmailTaken is returned when changing to an email address another user has.
+var ErrEmailTaken = errors.New("email already in use")
+
+// UserStore must satisfy the seeding interface so SeedFirstAdmin and Create
+// can't drift apart again.
+var _ auth.UserCreator = (*UserStore)(nil)
+
 type UserStore struct {
 	q       *dbpkg.Queries
 	db      *sql.DB
@@ -34,6 +45,9 @@ func (s *UserStore) CountAll(ctx context.Context) (int, error) {
 
 // Create inserts a new admin user, encrypting the email and computing its HMAC.
 func (s *UserStore) Create(ctx context.Context, id, username, email, passwordHash, role string) error {
+	if err := checkUsernameFree(ctx, s.q, username); err != nil {
+		return err
+	}
 	emailEnc, err := s.crypter.Encrypt([]byte(email))
 	if err != nil {
 		return fmt.Errorf("encrypt email: %w", err)
@@ -84,6 +98,12 @@ func (s *UserStore) GetByEmailHMAC(ctx context.Context, email string) (*model.Ad
 	return u, row.PasswordHash, nil
 }
 
+// GetByEmail looks up a user by plaintext email address. The address is
+// normalised and HMACed before the lookup, so matching is case-insensitive.
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*model.AdminUser, string, error) {
+	return s.GetByEmailHMAC(ctx, email)
+}
+
 // GetByUsername looks up a user by username.
 // Returns the user model and the password hash for verification.
 func (s *UserStore) GetByUsername(ctx context.Context, username string) (*model.AdminUser, string, error) {
@@ -194,15 +214,96 @@ func (s *UserStore) GetEmailByID(ctx context.Context, id string) (string, error)
 	if err != nil {
 		return "", fmt.Errorf("decrypt email: %w", err)
 	}
+	defer crypto.Zero(plain)
 	return string(plain), nil
 }
 
+// UpdateRoleAndStatus changes a user's role and status. It refuses to demote or
+// deactivate the last active super_admin, which would leave nobody able to
+// manage users. The check and the change share a transaction so two
+// concurrent demotions can't both pass it.
 func (s *UserStore) UpdateRoleAndStatus(ctx context.Context, id string, role model.Role, status model.Status) error {
-	return s.q.UpdateAdminUserRoleAndStatus(ctx, dbpkg.UpdateAdminUserRoleAndStatusParams{
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := s.q.WithTx(tx)
+	if role != model.RoleSuperAdmin || status != model.StatusActive {
+		last, err := isLastSuperAdmin(ctx, q, id)
+		if err != nil {
+			return err
+		}
+		if last {
+			return ErrLastSuperAdmin
+		}
+	}
+	if err := q.UpdateAdminUserRoleAndStatus(ctx, dbpkg.UpdateAdminUserRoleAndStatusParams{
 		Role:   string(role),
 		Status: string(status),
 		ID:     id,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isLastSuperAdmin reports whether id is the only active super_admin.
+func isLastSuperAdmin(ctx context.Context, q *dbpkg.Queries, id string) (bool, error) {
+	current, err := q.GetAdminUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("get user by id: %w", err)
+	}
+	if model.Role(current.Role) != model.RoleSuperAdmin || model.Status(current.Status) != model.StatusActive {
+		return false, nil
+	}
+	superCount, err := q.CountActiveSuperAdmins(ctx)
+	if err != nil {
+		return false, err
+	}
+	return superCount <= 1, nil
+}
+
+// UpdateEmail changes a user's email address, re-encrypting it and
+// recomputing its HMAC in one transaction. It returns ErrEmailTaken if the
+// address already belongs to another user.
+func (s *UserStore) UpdateEmail(ctx context.Context, id, email string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := s.q.WithTx(tx)
+	emailHMAC := crypto.EmailHMAC(s.hmacKey, email)
+	existing, err := q.GetAdminUserByEmailHMAC(ctx, emailHMAC)
+	if err == nil && existing.ID != id {
+		return ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("check email: %w", err)
+	}
+
+	emailEnc, err := s.crypter.Encrypt([]byte(email))
+	if err != nil {
+		return fmt.Errorf("encrypt email: %w", err)
+	}
+	n, err := q.UpdateAdminUserEmail(ctx, dbpkg.UpdateAdminUserEmailParams{
+		EmailHmac:      emailHMAC,
+		EmailEncrypted: emailEnc,
+		ID:             id,
 	})
+	if err != nil {
+		return fmt.Errorf("update email: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
 }
 
 // GetPasswordHashByID returns the bcrypt password hash for a user by their ID.
@@ -238,19 +339,27 @@ func (s *UserStore) UpdateLastLogin(ctx context.Context, id string) error {
 	return s.q.UpdateAdminUserLastLogin(ctx, id)
 }
 
+// Delete removes a user, refusing to remove the last active super_admin.
+// Like UpdateRoleAndStatus, the check and the delete share a transaction.
 func (s *UserStore) Delete(ctx context.Context, id string) error {
-	superCount, err := s.q.CountActiveSuperAdmins(ctx)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("begin transaction: %w", err)
 	}
-	role, err := s.q.GetAdminUserRoleByID(ctx, id)
+	defer func() { _ = tx.Rollback() }()
+
+	q := s.q.WithTx(tx)
+	last, err := isLastSuperAdmin(ctx, q, id)
 	if err != nil {
 		return err
 	}
-	if role == "super_admin" && superCount <= 1 {
-		return errLastSuperAdmin
+	if last {
+		return ErrLastSuperAdmin
+	}
+	if err := q.DeleteAdminUser(ctx, id); err != nil {
+		return err
 	}
-	return s.q.DeleteAdminUser(ctx, id)
+	return tx.Commit()
 }
 
 // CreateInvite stores a hashed invitation token with the email encrypted.
@@ -265,7 +374,7 @@ func (s *UserStore) CreateInvite(ctx context.Context, id, email, role, rawToken
 		EmailEncrypted: emailEnc,
 		Role:           role,
 		TokenHash:      hash,
-		ExpiresAt:      time.Now().Add(48 * time.Hour).UTC().Format("2006-01-02 15:04:05"),
+		ExpiresAt:      time.Now().Add(model.InviteTTL).UTC().Format("2006-01-02 15:04:05"),
 	})
 }
 
@@ -283,6 +392,7 @@ func (s *UserStore) GetInviteByToken(ctx context.Context, rawToken string) (*mod
 	if err != nil {
 		return nil, fmt.Errorf("decrypt invite email: %w", err)
 	}
+	defer crypto.Zero(emailPlain)
 	return &model.Invite{
 		ID:    row.ID,
 		Email: string(emailPlain),
@@ -305,6 +415,9 @@ func (s *UserStore) AcceptInvite(ctx context.Context, inviteID, userID, username
 	emailHMAC := crypto.EmailHMAC(s.hmacKey, email)
 
 	q := s.q.WithTx(tx)
+	if err := checkUsernameFree(ctx, q, username); err != nil {
+		return err
+	}
 	if err := q.CreateAdminUser(ctx, dbpkg.CreateAdminUserParams{
 		ID:             userID,
 		Username:       username,
@@ -321,7 +434,93 @@ func (s *UserStore) AcceptInvite(ctx context.Context, inviteID, userID, username
 	return tx.Commit()
 }
 
-var errLastSuperAdmin = errStr("cannot delete the last super_admin account")
+// UpgradeEmailHMACs recomputes the email HMAC of every user queued in
+// email_hmac_pending (see migration 011) and returns how many were rewritten.
+// It is safe to call on every startup; once the queue is empty it does nothing.
+func (s *UserStore) UpgradeEmailHMACs(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT u.id, u.email_encrypted FROM admin_users u JOIN email_hmac_pending p ON p.user_id = u.id`)
+	if err != nil {
+		return 0, fmt.Errorf("list pending users: %w", err)
+	}
+	type pending struct {
+		id   string
+		hmac string
+	}
+	var todo []pending
+	for rows.Next() {
+		var id string
+		var enc []byte
+		if err := rows.Scan(&id, &enc); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan pending user: %w", err)
+		}
+		plain, err := s.crypter.Decrypt(enc)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("decrypt email for %s: %w", id, err)
+		}
+		todo = append(todo, pending{id: id, hmac: crypto.EmailHMAC(s.hmacKey, string(plain))})
+		crypto.Zero(plain)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range todo {
+		if _, err := tx.ExecContext(ctx, `UPDATE admin_users SET email_hmac = ? WHERE id = ?`, p.hmac, p.id); err != nil {
+			return 0, fmt.Errorf("update email hmac for %s: %w", p.id, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_hmac_pending`); err != nil {
+		return 0, fmt.Errorf("clear pending queue: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(todo), nil
+}
+
+// SuggestUsername returns base if it is free, otherwise the first free
+// variant of it with a numeric suffix (base2, base3, ...).
+func (s *UserStore) SuggestUsername(ctx context.Context, base string) (string, error) {
+	candidate := base
+	for i := 2; i <= 100; i++ {
+		err := checkUsernameFree(ctx, s.q, candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, ErrUsernameTaken) {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", ErrUsernameTaken
+}
+
+// checkUsernameFree returns ErrUsernameTaken if username already belongs to a user.
+func checkUsernameFree(ctx context.Context, q *dbpkg.Queries, username string) error {
+	_, err := q.GetAdminUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check username: %w", err)
+	}
+	return ErrUsernameTaken
+}
+
+// ErrLastSuperAdmin is returned when deleting, demoting or deactivating the
+// only remaining active super_admin.
+var ErrLastSuperAdmin = errStr("cannot remove the last super_admin account")
 
 type errStr string
 
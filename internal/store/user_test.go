@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/firewatch/internal/auth"
+	"github.com/firewatch/internal/model"
+)
+
+func TestSeedFirstAdminWithUserStore(t *testing.T) {
+	t.Setenv("SEED_ADMIN_USERNAME", "")
+	t.Setenv("SEED_ADMIN_EMAIL", "Admin@Example.org")
+	t.Setenv("SEED_ADMIN_PASSWORD", "correct-horse-battery")
+
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	auth.SeedFirstAdmin(ctx, users)
+
+	u, hash, err := users.GetByUsername(ctx, "Admin")
+	if err != nil {
+		t.Fatalf("seeded user not found by username: %v", err)
+	}
+	if !auth.Verify(hash, "correct-horse-battery") {
+		t.Error("seeded password does not verify")
+	}
+	if !u.MustChangePassword {
+		t.Error("seeded user should be required to change password")
+	}
+
+	// Seeding again must not create a second account.
+	auth.SeedFirstAdmin(ctx, users)
+	if n, err := users.CountAll(ctx); err != nil || n != 1 {
+		t.Errorf("expected exactly 1 user after re-seed, got %d (err %v)", n, err)
+	}
+}
+
+func TestGetByEmailIsCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.Create(ctx, "u1", "alice", "Alice@Example.org", "hash", "admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, email := range []string{"alice@example.org", "ALICE@EXAMPLE.ORG", "  Alice@Example.org "} {
+		u, hash, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetByEmail(%q): %v", email, err)
+		}
+		if u.ID != "u1" || hash != "hash" {
+			t.Errorf("GetByEmail(%q) = %q/%q, want u1/hash", email, u.ID, hash)
+		}
+	}
+
+	if _, _, err := users.GetByEmail(ctx, "bob@example.org"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown email: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestInviteAcceptRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.CreateInvite(ctx, "inv1", "new.admin@example.org", "admin", "raw-token"); err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+
+	invite, err := users.GetInviteByToken(ctx, "raw-token")
+	if err != nil {
+		t.Fatalf("get invite: %v", err)
+	}
+	if invite.Email != "new.admin@example.org" || invite.Role != model.RoleAdmin {
+		t.Fatalf("invite = %+v", invite)
+	}
+
+	if err := users.AcceptInvite(ctx, invite.ID, "u1", "newadmin", invite.Email, "hash", string(invite.Role)); err != nil {
+		t.Fatalf("accept invite: %v", err)
+	}
+
+	u, hash, err := users.GetByUsername(ctx, "newadmin")
+	if err != nil {
+		t.Fatalf("get accepted user: %v", err)
+	}
+	if u.ID != "u1" || u.Role != model.RoleAdmin || hash != "hash" {
+		t.Errorf("accepted user = %+v (hash %q)", u, hash)
+	}
+	if _, _, err := users.GetByEmail(ctx, "new.admin@example.org"); err != nil {
+		t.Errorf("accepted user not found by email: %v", err)
+	}
+
+	// The token is single-use.
+	if _, err := users.GetInviteByToken(ctx, "raw-token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reused token: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUsernameCollision(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.Create(ctx, "u1", "admin", "admin@one.example", "hash", "admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	err := users.Create(ctx, "u2", "admin", "admin@two.example", "hash", "admin")
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("Create with duplicate username: got %v, want ErrUsernameTaken", err)
+	}
+
+	if err := users.CreateInvite(ctx, "inv1", "admin@two.example", "admin", "tok"); err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	err = users.AcceptInvite(ctx, "inv1", "u2", "admin", "admin@two.example", "hash", "admin")
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("AcceptInvite with duplicate username: got %v, want ErrUsernameTaken", err)
+	}
+	// A failed accept must leave the invite usable.
+	if _, err := users.GetInviteByToken(ctx, "tok"); err != nil {
+		t.Errorf("invite consumed by failed accept: %v", err)
+	}
+
+	got, err := users.SuggestUsername(ctx, "admin")
+	if err != nil || got != "admin2" {
+		t.Errorf("SuggestUsername = %q, %v; want admin2", got, err)
+	}
+	got, err = users.SuggestUsername(ctx, "free")
+	if err != nil || got != "free" {
+		t.Errorf("SuggestUsername = %q, %v; want free", got, err)
+	}
+}
+
+func TestUpdateRoleAndStatusKeepsLastSuperAdmin(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.Create(ctx, "root", "root", "root@example.org", "hash", "super_admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := users.UpdateRoleAndStatus(ctx, "root", model.RoleAdmin, model.StatusActive); !errors.Is(err, ErrLastSuperAdmin) {
+		t.Errorf("demote last super_admin: got %v, want ErrLastSuperAdmin", err)
+	}
+	if err := users.UpdateRoleAndStatus(ctx, "root", model.RoleSuperAdmin, model.StatusInactive); !errors.Is(err, ErrLastSuperAdmin) {
+		t.Errorf("deactivate last super_admin: got %v, want ErrLastSuperAdmin", err)
+	}
+
+	// With a second super_admin the demotion is allowed.
+	if err := users.Create(ctx, "other", "other", "other@example.org", "hash", "super_admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := users.UpdateRoleAndStatus(ctx, "root", model.RoleAdmin, model.StatusActive); err != nil {
+		t.Fatalf("demote with another super_admin present: %v", err)
+	}
+	u, err := users.GetByID(ctx, "root")
+	if err != nil || u.Role != model.RoleAdmin {
+		t.Errorf("after demote: %+v, %v", u, err)
+	}
+}
+
+func TestConcurrentDemotionsKeepOneSuperAdmin(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+	for _, id := range []string{"one", "two"} {
+		if err := users.Create(ctx, id, id, id+"@example.org", "hash", "super_admin"); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, id := range []string{"one", "two"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i == 0 {
+				errs[i] = users.UpdateRoleAndStatus(ctx, id, model.RoleAdmin, model.StatusActive)
+			} else {
+				errs[i] = users.Delete(ctx, id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	refused := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrLastSuperAdmin) {
+			refused++
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if refused != 1 {
+		t.Errorf("%d of 2 concurrent removals refused, want exactly 1", refused)
+	}
+	if n, err := users.q.CountActiveSuperAdmins(ctx); err != nil || n != 1 {
+		t.Errorf("active super_admins = %d, %v; want 1", n, err)
+	}
+}
+
+func TestUpgradeEmailHMACs(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.Create(ctx, "u1", "alice", "alice@example.org", "hash", "admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// Simulate a row written before the domain-separation tag existed.
+	mac := hmac.New(sha256.New, users.hmacKey)
+	mac.Write([]byte("alice@example.org"))
+	legacy := hex.EncodeToString(mac.Sum(nil))
+	if _, err := users.db.ExecContext(ctx, `UPDATE admin_users SET email_hmac = ? WHERE id = 'u1'`, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.db.ExecContext(ctx, `INSERT INTO email_hmac_pending (user_id) VALUES ('u1')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := users.GetByEmail(ctx, "alice@example.org"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("legacy digest should not match before upgrade, got %v", err)
+	}
+
+	n, err := users.UpgradeEmailHMACs(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("UpgradeEmailHMACs = %d, %v; want 1, nil", n, err)
+	}
+	if _, _, err := users.GetByEmail(ctx, "alice@example.org"); err != nil {
+		t.Errorf("lookup after upgrade: %v", err)
+	}
+
+	if n, err := users.UpgradeEmailHMACs(ctx); err != nil || n != 0 {
+		t.Errorf("second UpgradeEmailHMACs = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestUpdateEmail(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserStore(t)
+
+	if err := users.Create(ctx, "u1", "alice", "alice@example.org", "hash", "admin"); err != nil {
+		t.Fatalf("create alice: %v", err)
+	}
+	if err := users.Create(ctx, "u2", "bob", "bob@example.org", "hash", "admin"); err != nil {
+		t.Fatalf("create bob: %v", err)
+	}
+
+	if err := users.UpdateEmail(ctx, "u1", "alice@example.net"); err != nil {
+		t.Fatalf("UpdateEmail: %v", err)
+	}
+	if u, _, err := users.GetByEmail(ctx, "Alice@Example.net"); err != nil || u.ID != "u1" {
+		t.Errorf("login by new email: got %v, %v", u, err)
+	}
+	if _, _, err := users.GetByEmail(ctx, "alice@example.org"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old email still resolves: %v", err)
+	}
+	if got, err := users.GetEmailByID(ctx, "u1"); err != nil || got != "alice@example.net" {
+		t.Errorf("GetEmailByID = %q, %v", got, err)
+	}
+
+	// Re-saving your own address (in any case) is not a collision.
+	if err := users.UpdateEmail(ctx, "u1", "ALICE@example.net"); err != nil {
+		t.Errorf("UpdateEmail to own address: %v", err)
+	}
+	if err := users.UpdateEmail(ctx, "u1", "BOB@example.org"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("UpdateEmail to bob's address: got %v, want ErrEmailTaken", err)
+	}
+	if err := users.UpdateEmail(ctx, "nobody", "carol@example.org"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateEmail unknown user: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	dbpkg "github.com/firewatch/internal/db"
+)
+
+// AuditPanicWipe is the audit_log action recorded by PanicWipe.
+const AuditPanicWipe = "panic_wipe"
+
+// WipeResult counts what PanicWipe removed.
+type WipeResult struct {
+	Sessions    int64 `json:"sessions"`
+	Invites     int64 `json:"invites"`
+	Maintenance bool  `json:"maintenance"`
+}
+
+// PanicWipe signs every admin out, including userID, invalidates all pending
+// invites and, if maintenance is set, turns maintenance mode on, all in one
+// transaction. The counts are recorded in the audit log against userID.
+// Subscribers are notified of the settings change as for Save.
+func (s *SettingsStore) PanicWipe(ctx context.Context, userID string, maintenance bool) (WipeResult, error) {
+	// Load may seed and Save the settings, so warm the cache before taking
+	// saveMu, then read it again under the lock so no concurrent Save is lost.
+	if _, err := s.Load(ctx); err != nil {
+		return WipeResult{}, fmt.Errorf("load settings: %w", err)
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	settings, err := s.Load(ctx)
+	if err != nil {
+		return WipeResult{}, fmt.Errorf("load settings: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return WipeResult{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := s.q.WithTx(tx)
+
+	res := WipeResult{Maintenance: maintenance}
+	if res.Sessions, err = q.DeleteAllSessions(ctx); err != nil {
+		return WipeResult{}, fmt.Errorf("delete sessions: %w", err)
+	}
+	if res.Invites, err = q.InvalidatePendingInvites(ctx); err != nil {
+		return WipeResult{}, fmt.Errorf("invalidate invites: %w", err)
+	}
+	if maintenance {
+		settings.MaintenanceMode = true
+		ciphertext, err := s.encrypt(settings)
+		if err != nil {
+			return WipeResult{}, err
+		}
+		if err := q.UpsertSettings(ctx, ciphertext); err != nil {
+			return WipeResult{}, fmt.Errorf("save settings: %w", err)
+		}
+	}
+
+	detail, err := json.Marshal(res)
+	if err != nil {
+		return WipeResult{}, err
+	}
+	if err := q.InsertAuditLog(ctx, dbpkg.InsertAuditLogParams{
+		UserID: sql.NullString{String: userID, Valid: userID != ""},
+		Action: AuditPanicWipe,
+		Detail: sql.NullString{String: string(detail), Valid: true},
+	}); err != nil {
+		return WipeResult{}, fmt.Errorf("write audit log: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return WipeResult{}, err
+	}
+
+	if maintenance {
+		s.saved(settings)
+	}
+	return res, nil
+}
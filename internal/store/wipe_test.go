@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firewatch/internal/crypto"
+	"github.com/firewatch/internal/model"
+)
+
+func TestPanicWipe(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	key := make([]byte, 32)
+	users := NewUserStore(db, crypto.New(key), key)
+	sessions := NewSessionStore(db)
+	settings := NewSettingsStore(db, crypto.New(key))
+
+	for _, id := range []string{"u1", "u2"} {
+		if err := users.Create(ctx, id, id, id+"@example.org", "hash", "super_admin"); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	var ids []string
+	for _, id := range []string{"u1", "u1", "u2"} {
+		sid, err := sessions.Create(ctx, id)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		ids = append(ids, sid)
+	}
+	if err := users.CreateInvite(ctx, "inv1", "new@example.org", "admin", "raw-token"); err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	s, _ := settings.Load(ctx)
+	s.MaintenanceMode = false
+	if err := settings.Save(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	var notified []model.AppSettings
+	settings.Subscribe(func(s model.AppSettings) { notified = append(notified, s) })
+
+	res, err := settings.PanicWipe(ctx, "u1", true)
+	if err != nil {
+		t.Fatalf("PanicWipe: %v", err)
+	}
+	if res != (WipeResult{Sessions: 3, Invites: 1, Maintenance: true}) {
+		t.Errorf("result = %+v", res)
+	}
+	for _, sid := range ids {
+		if _, err := sessions.GetUserID(ctx, sid); err == nil {
+			t.Errorf("session %s survived the wipe", sid)
+		}
+	}
+	if _, err := users.GetInviteByToken(ctx, "raw-token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("invite after wipe: got %v, want ErrNotFound", err)
+	}
+	if got, _ := settings.Load(ctx); !got.MaintenanceMode {
+		t.Error("maintenance mode not turned on")
+	}
+	if fromDB, err := settings.load(ctx); err != nil || !fromDB.MaintenanceMode {
+		t.Errorf("maintenance mode not persisted (err %v)", err)
+	}
+	if len(notified) != 1 || !notified[0].MaintenanceMode {
+		t.Errorf("subscribers notified %d times", len(notified))
+	}
+
+	var userID, action, detail string
+	if err := db.QueryRowContext(ctx, `SELECT user_id, action, detail FROM audit_log`).Scan(&userID, &action, &detail); err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	if userID != "u1" || action != AuditPanicWipe || detail != `{"sessions":3,"invites":1,"maintenance":true}` {
+		t.Errorf("audit row = %q %q %q", userID, action, detail)
+	}
+
+	// Without maintenance the settings are left alone.
+	s, _ = settings.Load(ctx)
+	s.MaintenanceMode = false
+	if err := settings.Save(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := settings.PanicWipe(ctx, "u1", false); err != nil {
+		t.Fatalf("PanicWipe: %v", err)
+	}
+	if got, _ := settings.Load(ctx); got.MaintenanceMode {
+		t.Error("maintenance mode turned on without being asked")
+	}
+}
@@ -22,6 +22,15 @@ var StaticFS fs.FS
 // Templates is the compiled template set for all views.
 var Templates *template.Template
 
+// basePath is prepended to every path passed to the "url" template function.
+var basePath string
+
+// SetBasePath sets the prefix the "url" template function adds to app paths,
+// e.g. "/firewatch". Call it before serving any request.
+func SetBasePath(prefix string) {
+	basePath = prefix
+}
+
 func init() {
 	var err error
 
@@ -35,6 +44,8 @@ func init() {
 	Templates, err = template.New("").Funcs(template.FuncMap{
 		"appVersion": func() string { return version },
 		"appCommit":  func() string { return commit },
+		// url returns an app path ("/admin/login") under the base path.
+		"url": func(path string) string { return basePath + path },
 		// splitLines splits a string on newlines, dropping blank lines.
 		// Used by accordion fields to render each line as a checklist item.
 		"upper": strings.ToUpper,